@@ -32,15 +32,154 @@ type GithubIssueSpec struct {
 	// operator-sdk create webhook --group training --version v1alpha1 --kind GithubIssue  --programmatic-validation
 	// How to add CRD validation? -> https://book.kubebuilder.io/reference/markers/crd-validation.html or https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html#validation
 
-	// +kubebuilder:validation:Pattern=`^https?:\/\/github.com+/[a-zA-Z0-9\_.-]+/[a-zA-Z0-9\_.-]`
-	// Represent the github repo's URL - e.g https://github.com/rgolangh/dotfiles
-	Repo string `json:"repo"`
+	// Represent the github repo's URL - e.g https://github.com/rgolangh/dotfiles, git@github.com:rgolangh/dotfiles.git
+	// or https://<host>/<owner>/<repo> for Github Enterprise. Either repo or repository must be set.
+	// +optional
+	Repo string `json:"repo,omitempty"`
+	// The repo by its host, owner and name, an alternative to repo
+	// +optional
+	Repository *RepositoryRef `json:"repository,omitempty"`
+	// The system hosting the repo - github, gitlab, gitea (for Gitea and Forgejo) or jira. When it is unset it is taken from
+	// the operator's hosts configuration, or guessed from the repo's host (gitlab for a host like gitlab.com or
+	// gitlab.example.com, gitea for gitea.example.com or forgejo.example.com, jira for jira.example.com or
+	// <site>.atlassian.net, github otherwise). A Jira repo is its project, https://<host>/projects/<project key>
+	// +optional
+	// +kubebuilder:validation:Enum=github;gitlab;gitea;jira
+	Provider string `json:"provider,omitempty"`
 	// The title of the issue
 	Title string `json:"title"`
 	// The issue's description
 	Description string `json:"description"`
+	// The number of an existing issue to adopt instead of creating a new one
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	IssueNumber int `json:"issueNumber,omitempty"`
+	// Adopt the existing issue whose title is spec.title, a new issue is created when there is none
+	// +optional
+	AdoptByTitle bool `json:"adoptByTitle,omitempty"`
+	// The labels of the issue, the issue's labels are left as is when it is unset and removed when it is an empty list
+	// +optional
+	Labels []string `json:"labels,omitempty"`
+	// The logins of the issue's assignees, the issue's assignees are left as is when it is empty
+	// +optional
+	Assignees []string `json:"assignees,omitempty"`
+	// The number of the issue's milestone, the issue's milestone is left as is when it is unset
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	Milestone *int `json:"milestone,omitempty"`
+	// The desired state of the issue - open or closed, the issue's state is left as is when it is unset
+	// +optional
+	// +kubebuilder:validation:Enum=open;closed
+	State string `json:"state,omitempty"`
+	// The reason for the state - completed or not_planned when closing, reopened when opening
+	// +optional
+	// +kubebuilder:validation:Enum=completed;not_planned;reopened
+	StateReason string `json:"stateReason,omitempty"`
+	// Which side wins when a field of the issue is changed on Github, per field
+	// +optional
+	SyncPolicy *SyncPolicy `json:"syncPolicy,omitempty"`
+	// What happens to the issue when the GithubIssue is deleted - Close (the default), Orphan (leave it as is),
+	// Lock (close and lock the conversation) or CommentAndClose (post spec.closingComment and close)
+	// +optional
+	// +kubebuilder:default=Close
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// The comment posted with the CommentAndClose deletion policy, a default message is used when it is empty
+	// +optional
+	ClosingComment string `json:"closingComment,omitempty"`
+	// Comments posted on the issue, e.g. status updates. A comment is edited when its body changes here
+	// +optional
+	// +listType=map
+	// +listMapKey=key
+	Comments []IssueComment `json:"comments,omitempty"`
+	// Delete the comment of a key removed from spec.comments, it is left on the issue when this is false
+	// +optional
+	PruneComments bool `json:"pruneComments,omitempty"`
+	// Reference to a Secret in the GithubIssue's namespace holding the Github token.
+	// When it is unset the operator's global token (GIT_TOKEN_GI) is used.
+	// Label the Secret training.githubissues/credentials=true to have it watched, so a rotated token is used right away;
+	// an unlabelled Secret is read on every reconcile, its rotation is picked up on the next resync and a CredentialsNotWatched Event is recorded
+	// +optional
+	CredentialsSecretRef *SecretKeyReference `json:"credentialsSecretRef,omitempty"`
 }
 
+// IssueComment is a comment of the issue, identified by its key
+type IssueComment struct {
+	// The stable key of the comment, which ties it to the comment on Github across edits
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.-]+$`
+	// +kubebuilder:validation:MaxLength=63
+	Key string `json:"key"`
+	// The comment's body
+	Body string `json:"body"`
+}
+
+// RepositoryRef identifies a repo by its host, owner and name
+type RepositoryRef struct {
+	// The host of the repo, github.com when it is empty
+	// +optional
+	Host string `json:"host,omitempty"`
+	// The user or organization owning the repo
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.-]+$`
+	Owner string `json:"owner"`
+	// The name of the repo
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.-]+$`
+	Name string `json:"name"`
+}
+
+// SyncMode is how a field changed on Github is handled
+// +kubebuilder:validation:Enum=KubernetesWins;GitHubWins;ReportOnly
+type SyncMode string
+
+const (
+	// SyncModeKubernetesWins overwrites the field on Github with the spec, the default
+	SyncModeKubernetesWins SyncMode = "KubernetesWins"
+	// SyncModeGitHubWins keeps the field as changed on Github and reports it in the status
+	SyncModeGitHubWins SyncMode = "GitHubWins"
+	// SyncModeReportOnly keeps the field as changed on Github, and raises the Drifted condition and an Event
+	SyncModeReportOnly SyncMode = "ReportOnly"
+)
+
+// SyncPolicy selects the SyncMode of each field, an unset field is KubernetesWins
+type SyncPolicy struct {
+	// +optional
+	Title SyncMode `json:"title,omitempty"`
+	// +optional
+	Body SyncMode `json:"body,omitempty"`
+	// +optional
+	Labels SyncMode `json:"labels,omitempty"`
+	// +optional
+	Assignees SyncMode `json:"assignees,omitempty"`
+	// +optional
+	State SyncMode `json:"state,omitempty"`
+}
+
+// DeletionPolicy is what happens to the issue on Github when its GithubIssue is deleted
+// +kubebuilder:validation:Enum=Close;Orphan;Lock;CommentAndClose
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyClose closes the issue
+	DeletionPolicyClose DeletionPolicy = "Close"
+	// DeletionPolicyOrphan leaves the issue as is
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+	// DeletionPolicyLock closes the issue and locks its conversation
+	DeletionPolicyLock DeletionPolicy = "Lock"
+	// DeletionPolicyCommentAndClose posts the closing comment and closes the issue
+	DeletionPolicyCommentAndClose DeletionPolicy = "CommentAndClose"
+)
+
+// SecretKeyReference selects a key of a Secret in the same namespace
+type SecretKeyReference struct {
+	// The name of the Secret
+	Name string `json:"name"`
+	// The key in the Secret's data which holds the token, github-token if empty
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// CredentialsSecretLabel set to "true" on a Secret referenced by spec.credentialsSecretRef has the Secret watched,
+// so a rotated token is used right away. Other Secrets are read directly on every reconcile
+const CredentialsSecretLabel = "training.githubissues/credentials"
+
 // GithubIssueStatus defines the observed state of GithubIssue
 type GithubIssueStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
@@ -51,10 +190,121 @@ type GithubIssueStatus struct {
 	LastUpdateTimestamp string `json:"lastUpdateTimestamp"`
 	// The issue's number - used as primary key for finding if this is a new githubIssue
 	Number int `json:"number,omitempty"`
+	// The issue was adopted by spec.issueNumber or spec.adoptByTitle rather than created by the operator,
+	// so no marker is written into its body
+	// +optional
+	Adopted bool `json:"adopted,omitempty"`
+	// The issue's key on a tracker which identifies issues by one, e.g. OPS-123 on Jira, whose number is the part after the dash
+	// +optional
+	Key string `json:"key,omitempty"`
+	// The title of the github issue, as last observed
+	// +optional
+	Title string `json:"title,omitempty"`
+	// The description of the github issue, as last observed - reported when spec.syncPolicy.body isn't KubernetesWins
+	// +optional
+	Description string `json:"description,omitempty"`
+	// The reason for the state of the github issue, as last observed
+	// +optional
+	StateReason string `json:"stateReason,omitempty"`
+	// The labels of the github issue, as last observed
+	// +optional
+	Labels []string `json:"labels,omitempty"`
+	// The logins of the github issue's assignees, as last observed
+	// +optional
+	Assignees []string `json:"assignees,omitempty"`
+	// The number of the github issue's milestone, as last observed
+	// +optional
+	Milestone int `json:"milestone,omitempty"`
+	// The generation of the spec which was last synced with Github
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// The comments posted for spec.comments, by key
+	// +optional
+	// +listType=map
+	// +listMapKey=key
+	Comments []CommentStatus `json:"comments,omitempty"`
+	// Conditions of the issue - Ready, Synced, CredentialsValid and RepoAccessible
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// CommentStatus is the comment posted on Github for a key of spec.comments
+type CommentStatus struct {
+	// The key of the comment in spec.comments
+	Key string `json:"key"`
+	// The ID of the comment on Github
+	ID int64 `json:"id"`
+	// The SHA-256 of the body last posted, the comment is edited once the body in spec.comments differs
+	BodyHash string `json:"bodyHash"`
 }
 
+// Issue states of GithubIssueSpec.State
+const (
+	StateOpen   = "open"
+	StateClosed = "closed"
+)
+
+// Providers of GithubIssueSpec.Provider
+const (
+	ProviderGithub = "github"
+	ProviderGitlab = "gitlab"
+	ProviderGitea  = "gitea"
+	ProviderJira   = "jira"
+)
+
+// Condition types of GithubIssueStatus.Conditions
+const (
+	// ConditionReady is true when the issue on Github matches the spec and every other condition is true
+	ConditionReady = "Ready"
+	// ConditionSynced is true when the last sync with Github succeeded
+	ConditionSynced = "Synced"
+	// ConditionCredentialsValid is false when the token can't be resolved or Github rejects it
+	ConditionCredentialsValid = "CredentialsValid"
+	// ConditionDrifted is true when a ReportOnly field of the issue differs from the spec
+	ConditionDrifted = "Drifted"
+	// ConditionRepoAccessible is false when the repo doesn't exist or the token has no access to it
+	ConditionRepoAccessible = "RepoAccessible"
+)
+
+// Condition reasons of GithubIssueStatus.Conditions
+const (
+	ReasonSucceeded              = "Succeeded"
+	ReasonNotFound               = "NotFound"
+	ReasonForbidden              = "Forbidden"
+	ReasonUnauthorized           = "Unauthorized"
+	ReasonRateLimited            = "RateLimited"
+	ReasonInvalidRepo            = "InvalidRepo"
+	ReasonUnknownHost            = "UnknownHost"
+	ReasonUnexpectedResponse     = "UnexpectedResponse"
+	ReasonValidationFailed       = "ValidationFailed"
+	ReasonRequestFailed          = "RequestFailed"
+	ReasonCredentialsUnavailable = "CredentialsUnavailable"
+	ReasonNotSynced              = "NotSynced"
+	ReasonAlreadyOwned           = "AlreadyOwned"
+	ReasonDrifted                = "Drifted"
+	ReasonInSync                 = "InSync"
+)
+
+// Event reasons of the changes made on Github, failures are recorded with the condition reasons
+const (
+	ReasonCreated = "Created"
+	ReasonAdopted = "Adopted"
+	ReasonUpdated = "Updated"
+	ReasonClosed  = "Closed"
+	// ReasonCommented is the Event of creating, editing or deleting comments of spec.comments
+	ReasonCommented = "Commented"
+	// ReasonCredentialsNotWatched is the Warning Event of a token read from a Secret without CredentialsSecretLabel
+	ReasonCredentialsNotWatched = "CredentialsNotWatched"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Number",type=integer,JSONPath=`.status.number`
+//+kubebuilder:printcolumn:name="Key",type=string,JSONPath=`.status.key`,priority=1
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
 
 // GithubIssue is the Schema for the githubissues API
 type GithubIssue struct {
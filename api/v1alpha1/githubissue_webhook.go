@@ -0,0 +1,173 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+const (
+	// MaxTitleLength is the longest issue title Github accepts
+	MaxTitleLength = 256
+	// MaxBodyLength is the longest issue body Github accepts
+	MaxBodyLength = 65536
+	// MaxMarkerLength is the longest the hidden marker appended to an issue's body is, with the blank line before it -
+	// "\n\n<!-- githubissues-operator:uid=<UID> -->" with a 36 character UID (see github.Marker).
+	// A comment's marker is longer by ":comment=" and its key (see github.CommentBody)
+	MaxMarkerLength = len("\n\n<!-- githubissues-operator:uid=") + 36 + len(" -->")
+)
+
+// log is for logging in this package.
+var githubissuelog = logf.Log.WithName("githubissue-resource")
+
+// SetupWebhookWithManager registers the defaulting and validating webhooks of GithubIssue with the manager
+func (r *GithubIssue) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-training-githubissues-v1alpha1-githubissue,mutating=true,failurePolicy=fail,sideEffects=None,groups=training.githubissues,resources=githubissues,verbs=create;update,versions=v1alpha1,name=mgithubissue.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Defaulter = &GithubIssue{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *GithubIssue) Default() {
+	githubissuelog.Info("default", "name", r.Name)
+
+	if r.Spec.DeletionPolicy == "" {
+		r.Spec.DeletionPolicy = DeletionPolicyClose
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-training-githubissues-v1alpha1-githubissue,mutating=false,failurePolicy=fail,sideEffects=None,groups=training.githubissues,resources=githubissues,verbs=create;update,versions=v1alpha1,name=vgithubissue.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &GithubIssue{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *GithubIssue) ValidateCreate() error {
+	githubissuelog.Info("validate create", "name", r.Name)
+
+	return r.invalid(r.validateSpec())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *GithubIssue) ValidateUpdate(old runtime.Object) error {
+	githubissuelog.Info("validate update", "name", r.Name)
+
+	oldGithubIssue, ok := old.(*GithubIssue)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a GithubIssue but got a %T", old))
+	}
+	if equality.Semantic.DeepEqual(oldGithubIssue.Spec, r.Spec) {
+		return nil // e.g. the controller (un)registering its finalizer on a GithubIssue created before this webhook
+	}
+	allErrs := r.validateSpec()
+	if oldGithubIssue.Status.Number > 0 && !sameRepo(oldGithubIssue.Spec, r.Spec) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "repository"),
+			fmt.Sprintf("the repo can't be changed once issue %d was assigned, create another GithubIssue instead", oldGithubIssue.Status.Number)))
+	}
+	if oldGithubIssue.Status.Number > 0 && oldGithubIssue.Spec.Provider != r.Spec.Provider {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "provider"),
+			fmt.Sprintf("the provider can't be changed once issue %d was assigned, create another GithubIssue instead", oldGithubIssue.Status.Number)))
+	}
+	return r.invalid(allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *GithubIssue) ValidateDelete() error {
+	return nil
+}
+
+// validateSpec checks the spec against Github's limits
+func (r *GithubIssue) validateSpec() field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	if _, err := r.Spec.ResolveRepository(); err != nil {
+		if r.Spec.Repository != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("repository"), r.Spec.Repository, err.Error()))
+		} else {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("repo"), r.Spec.Repo, err.Error()))
+		}
+	}
+	if r.Spec.Provider != "" && r.Spec.Provider != ProviderGithub && r.Spec.StateReason != "" {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("stateReason"), r.Spec.Provider+" issues have no state reason"))
+	}
+	if r.Spec.Provider == ProviderJira {
+		if len(r.Spec.Assignees) > 1 {
+			allErrs = append(allErrs, field.TooMany(specPath.Child("assignees"), len(r.Spec.Assignees), 1))
+		}
+		if r.Spec.Milestone != nil {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("milestone"), "jira issues have no milestone"))
+		}
+	}
+	if r.Spec.Title == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("title"), "an issue must have a title"))
+	} else if length := utf8.RuneCountInString(r.Spec.Title); length > MaxTitleLength {
+		allErrs = append(allErrs, field.TooLong(specPath.Child("title"), fmt.Sprintf("%d characters", length), MaxTitleLength))
+	}
+	maxDescription := MaxBodyLength - MaxMarkerLength
+	if r.Annotations[ImportedByAnnotation] != "" { // no marker is appended to an imported issue's body
+		maxDescription = MaxBodyLength
+	}
+	if length := utf8.RuneCountInString(r.Spec.Description); length > maxDescription {
+		allErrs = append(allErrs, field.TooLong(specPath.Child("description"), fmt.Sprintf("%d characters", length), maxDescription))
+	}
+	keys := make(map[string]bool, len(r.Spec.Comments))
+	for i, comment := range r.Spec.Comments {
+		commentPath := specPath.Child("comments").Index(i)
+		if keys[comment.Key] {
+			allErrs = append(allErrs, field.Duplicate(commentPath.Child("key"), comment.Key))
+		}
+		keys[comment.Key] = true
+		if strings.TrimSpace(comment.Body) == "" {
+			allErrs = append(allErrs, field.Required(commentPath.Child("body"), "a comment needs a body"))
+		} else if maxComment := MaxBodyLength - MaxMarkerLength - len(":comment=") - len(comment.Key); utf8.RuneCountInString(comment.Body) > maxComment {
+			allErrs = append(allErrs, field.TooLong(commentPath.Child("body"), fmt.Sprintf("%d characters", utf8.RuneCountInString(comment.Body)), maxComment))
+		}
+	}
+	return allErrs
+}
+
+// sameRepo tells if both specs are of the same repo, in whatever form it was given
+func sameRepo(old GithubIssueSpec, updated GithubIssueSpec) bool {
+	oldRef, err := old.ResolveRepository()
+	if err != nil {
+		return old.Repo == updated.Repo && equality.Semantic.DeepEqual(old.Repository, updated.Repository)
+	}
+	updatedRef, err := updated.ResolveRepository()
+	return err == nil && oldRef.Key() == updatedRef.Key()
+}
+
+// invalid wraps allErrs in an Invalid error, nil when there are none
+func (r *GithubIssue) invalid(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "GithubIssue"}, r.Name, allErrs)
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+var _ = Describe("GithubIssue webhook", func() {
+	var githubi *GithubIssue
+
+	BeforeEach(func() {
+		githubi = &GithubIssue{Spec: GithubIssueSpec{
+			Repo:        "https://github.com/razo7/githubissues-operator",
+			Title:       "K8s Issue",
+			Description: "Hi from testing K8s",
+		}}
+	})
+
+	It("should default the deletion policy", func() {
+		githubi.Default()
+		Expect(githubi.Spec.DeletionPolicy).To(Equal(DeletionPolicyClose))
+		githubi.Spec.DeletionPolicy = DeletionPolicyOrphan
+		githubi.Default()
+		Expect(githubi.Spec.DeletionPolicy).To(Equal(DeletionPolicyOrphan))
+	})
+
+	It("should accept a valid GithubIssue", func() {
+		Expect(githubi.ValidateCreate()).To(Succeed())
+		githubi.Spec.Repo += "/"
+		Expect(githubi.ValidateCreate()).To(Succeed())
+	})
+
+	It("should reject an empty or too long title", func() {
+		githubi.Spec.Title = ""
+		Expect(apierrors.IsInvalid(githubi.ValidateCreate())).To(BeTrue())
+		githubi.Spec.Title = strings.Repeat("t", MaxTitleLength)
+		Expect(githubi.ValidateCreate()).To(Succeed())
+		githubi.Spec.Title += "t"
+		Expect(apierrors.IsInvalid(githubi.ValidateCreate())).To(BeTrue())
+	})
+
+	It("should reject a too long description", func() {
+		githubi.Spec.Description = strings.Repeat("d", MaxBodyLength+1)
+		Expect(apierrors.IsInvalid(githubi.ValidateCreate())).To(BeTrue())
+	})
+
+	It("should leave room for the marker in the description", func() {
+		githubi.Spec.Description = strings.Repeat("d", MaxBodyLength-MaxMarkerLength)
+		Expect(githubi.ValidateCreate()).To(Succeed())
+		githubi.Spec.Description += "d"
+		Expect(apierrors.IsInvalid(githubi.ValidateCreate())).To(BeTrue())
+
+		By("accepting a full length description of an imported issue, which has no marker")
+		githubi.Annotations = map[string]string{ImportedByAnnotation: "default/backlog-import"}
+		githubi.Spec.Description = strings.Repeat("d", MaxBodyLength)
+		Expect(githubi.ValidateCreate()).To(Succeed())
+	})
+
+	It("should reject duplicate comment keys and empty or too long comments", func() {
+		githubi.Spec.Comments = []IssueComment{{Key: "deploy", Body: "deploy of v1.4 finished"}, {Key: "rollback", Body: "no rollback needed"}}
+		Expect(githubi.ValidateCreate()).To(Succeed())
+		githubi.Spec.Comments[1].Key = "deploy"
+		Expect(apierrors.IsInvalid(githubi.ValidateCreate())).To(BeTrue())
+		githubi.Spec.Comments[1] = IssueComment{Key: "rollback", Body: " "}
+		Expect(apierrors.IsInvalid(githubi.ValidateCreate())).To(BeTrue())
+		githubi.Spec.Comments[1].Body = strings.Repeat("c", MaxBodyLength+1)
+		Expect(apierrors.IsInvalid(githubi.ValidateCreate())).To(BeTrue())
+		githubi.Spec.Comments[1].Body = strings.Repeat("c", MaxBodyLength-MaxMarkerLength-len(":comment=rollback"))
+		Expect(githubi.ValidateCreate()).To(Succeed())
+		githubi.Spec.Comments[1].Body += "c"
+		Expect(apierrors.IsInvalid(githubi.ValidateCreate())).To(BeTrue())
+	})
+
+	It("should reject malformed repo URLs", func() {
+		for _, repo := range []string{
+			"https://github.com/razo7",
+			"https://github.com/razo7/githubissues-operator/issues",
+			"https://github.com/razo7/githubissues-operator/tree/main",
+			"https://github.com/razo7/githubissues-operator?tab=issues",
+			"ftp://github.com/razo7/githubissues-operator",
+			"",
+		} {
+			githubi.Spec.Repo = repo
+			Expect(apierrors.IsInvalid(githubi.ValidateCreate())).To(BeTrue(), repo)
+		}
+	})
+
+	It("should accept spec.repository instead of spec.repo, but not a different repo in both", func() {
+		githubi.Spec.Repository = &RepositoryRef{Owner: "razo7", Name: "githubissues-operator"}
+		Expect(githubi.ValidateCreate()).To(Succeed())
+		githubi.Spec.Repo = ""
+		Expect(githubi.ValidateCreate()).To(Succeed())
+		githubi.Spec.Repo = "https://github.com/razo7/another-repo"
+		Expect(apierrors.IsInvalid(githubi.ValidateCreate())).To(BeTrue())
+	})
+
+	It("should forbid changing the repo once an issue number is assigned", func() {
+		old := githubi.DeepCopy()
+		githubi.Spec.Repo = "https://github.com/razo7/another-repo"
+		Expect(githubi.ValidateUpdate(old)).To(Succeed())
+		old.Status.Number = 3
+		Expect(apierrors.IsInvalid(githubi.ValidateUpdate(old))).To(BeTrue())
+
+		By("give the same repo in another form")
+		githubi.Spec.Repo = "git@github.com:Razo7/githubissues-operator.git"
+		Expect(githubi.ValidateUpdate(old)).To(Succeed())
+	})
+
+	It("should reject a state reason on GitLab and forbid changing the provider once an issue number is assigned", func() {
+		githubi.Spec.Provider = ProviderGitlab
+		githubi.Spec.State, githubi.Spec.StateReason = StateClosed, "not_planned"
+		Expect(apierrors.IsInvalid(githubi.ValidateCreate())).To(BeTrue())
+		githubi.Spec.StateReason = ""
+		Expect(githubi.ValidateCreate()).To(Succeed())
+
+		old := githubi.DeepCopy()
+		old.Status.Number = 3
+		githubi.Spec.Provider = ProviderGithub
+		Expect(apierrors.IsInvalid(githubi.ValidateUpdate(old))).To(BeTrue())
+	})
+
+	It("should reject more than one assignee and a milestone on Jira", func() {
+		githubi.Spec.Repo = "https://jira.example.com/projects/OPS"
+		githubi.Spec.Provider = ProviderJira
+		githubi.Spec.Assignees = []string{"alice"}
+		Expect(githubi.ValidateCreate()).To(Succeed())
+		githubi.Spec.Assignees = append(githubi.Spec.Assignees, "bob")
+		Expect(apierrors.IsInvalid(githubi.ValidateCreate())).To(BeTrue())
+		githubi.Spec.Assignees = githubi.Spec.Assignees[:1]
+		milestone := 2
+		githubi.Spec.Milestone = &milestone
+		Expect(apierrors.IsInvalid(githubi.ValidateCreate())).To(BeTrue())
+	})
+
+	It("should let an unchanged spec through, e.g. for removing the finalizer", func() {
+		githubi.Spec.Title = ""
+		old := githubi.DeepCopy()
+		githubi.Finalizers = nil
+		Expect(githubi.ValidateUpdate(old)).To(Succeed())
+	})
+})
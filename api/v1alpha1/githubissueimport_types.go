@@ -0,0 +1,136 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GithubIssueImportSpec defines the desired state of GithubIssueImport
+type GithubIssueImportSpec struct {
+	// The URL of the repo whose issues are imported, as in GithubIssueSpec.Repo
+	Repo string `json:"repo"`
+	// The system hosting the repo, as in GithubIssueSpec.Provider
+	// +optional
+	// +kubebuilder:validation:Enum=github;gitlab;gitea;jira
+	Provider string `json:"provider,omitempty"`
+	// Import only the issues which have all these labels
+	// +optional
+	Labels []string `json:"labels,omitempty"`
+	// Import the issues in this state - open (the default), closed or all
+	// +optional
+	// +kubebuilder:default=open
+	// +kubebuilder:validation:Enum=open;closed;all
+	State string `json:"state,omitempty"`
+	// Import only the issues updated at or after this time
+	// +optional
+	Since *metav1.Time `json:"since,omitempty"`
+	// The namespace of the created GithubIssues, it can only be the GithubIssueImport's own namespace, which is used when it is empty.
+	// The GithubIssues resolve spec.credentialsSecretRef in it as well
+	// +optional
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+	// The Go template of the created GithubIssues' names, with the fields .Owner, .Repo, .Number, .Key and .Title of the issue.
+	// The result is lowercased and every run of characters a name can't have becomes a dash. {{.Repo}}-{{.Number}} when it is empty
+	// +optional
+	NameTemplate string `json:"nameTemplate,omitempty"`
+	// The deletion policy of the created GithubIssues, see GithubIssueSpec.DeletionPolicy
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// Reference to a Secret holding the token, in the GithubIssueImport's namespace for listing the issues.
+	// It is set on the created GithubIssues too, so the Secret must exist in the target namespace as well.
+	// When it is unset the credentials of the repo's host, or the operator's global token, are used
+	// +optional
+	CredentialsSecretRef *SecretKeyReference `json:"credentialsSecretRef,omitempty"`
+}
+
+// GithubIssueImportStatus defines the observed state of GithubIssueImport
+type GithubIssueImportStatus struct {
+	// How many GithubIssues were created by the last import
+	// +optional
+	Imported int `json:"imported,omitempty"`
+	// How many matching issues were skipped by the last import, as another GithubIssue already manages them
+	// or a GithubIssue already has their name
+	// +optional
+	Skipped int `json:"skipped,omitempty"`
+	// When the last import finished
+	// +optional
+	LastImportTime *metav1.Time `json:"lastImportTime,omitempty"`
+	// The generation of the spec which was last imported
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions of the import - Ready
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Issue states of GithubIssueImportSpec.State, on top of StateOpen and StateClosed
+const (
+	StateAll = "all"
+)
+
+// ValidateTargetNamespace checks that spec.targetNamespace is empty or namespace, the import's own namespace
+func (s GithubIssueImportSpec) ValidateTargetNamespace(namespace string) error {
+	if s.TargetNamespace != "" && s.TargetNamespace != namespace {
+		return fmt.Errorf("GithubIssues can only be imported into the import's namespace %q, not %q", namespace, s.TargetNamespace)
+	}
+	return nil
+}
+
+// ImportedByAnnotation is set on the GithubIssues created by a GithubIssueImport, to its namespace/name
+const ImportedByAnnotation = "training.githubissues/imported-by"
+
+// Condition and Event reasons of GithubIssueImports, on top of the GithubIssue ones
+const (
+	// ReasonImported is the Event of the GithubIssues created by an import
+	ReasonImported = "Imported"
+	// ReasonForbiddenNamespace is the Ready condition of an import whose spec.targetNamespace isn't its own namespace
+	ReasonForbiddenNamespace = "ForbiddenNamespace"
+	// ReasonInvalidNameTemplate is the Ready condition of an import whose spec.nameTemplate can't make a GithubIssue's name
+	ReasonInvalidNameTemplate = "InvalidNameTemplate"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Repo",type=string,JSONPath=`.spec.repo`
+//+kubebuilder:printcolumn:name="Imported",type=integer,JSONPath=`.status.imported`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+
+// GithubIssueImport is the Schema for the githubissueimports API - it creates a GithubIssue for every matching issue of a repo
+type GithubIssueImport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GithubIssueImportSpec   `json:"spec,omitempty"`
+	Status GithubIssueImportStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// GithubIssueImportList contains a list of GithubIssueImport
+type GithubIssueImportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GithubIssueImport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GithubIssueImport{}, &GithubIssueImportList{})
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var githubissueimportlog = logf.Log.WithName("githubissueimport-resource")
+
+// SetupWebhookWithManager registers the validating webhook of GithubIssueImport with the manager
+func (r *GithubIssueImport) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-training-githubissues-v1alpha1-githubissueimport,mutating=false,failurePolicy=fail,sideEffects=None,groups=training.githubissues,resources=githubissueimports,verbs=create;update,versions=v1alpha1,name=vgithubissueimport.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &GithubIssueImport{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *GithubIssueImport) ValidateCreate() error {
+	githubissueimportlog.Info("validate create", "name", r.Name)
+
+	return r.invalid(r.validateSpec())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *GithubIssueImport) ValidateUpdate(old runtime.Object) error {
+	githubissueimportlog.Info("validate update", "name", r.Name)
+
+	return r.invalid(r.validateSpec())
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *GithubIssueImport) ValidateDelete() error {
+	return nil
+}
+
+// validateSpec rejects a target namespace other than the import's own, the operator would create GithubIssues
+// where whoever created the import may not
+func (r *GithubIssueImport) validateSpec() field.ErrorList {
+	var allErrs field.ErrorList
+	if err := r.Spec.ValidateTargetNamespace(r.Namespace); err != nil {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "targetNamespace"), err.Error()))
+	}
+	return allErrs
+}
+
+// invalid wraps allErrs in an Invalid error, nil when there are none
+func (r *GithubIssueImport) invalid(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "GithubIssueImport"}, r.Name, allErrs)
+}
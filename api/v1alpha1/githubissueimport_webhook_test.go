@@ -0,0 +1,47 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("GithubIssueImport webhook", func() {
+	var imp *GithubIssueImport
+
+	BeforeEach(func() {
+		imp = &GithubIssueImport{
+			ObjectMeta: metav1.ObjectMeta{Name: "backlog-import", Namespace: "backlog"},
+			Spec:       GithubIssueImportSpec{Repo: "https://github.com/razo7/githubissues-operator"},
+		}
+	})
+
+	It("should accept the import's own namespace as the target", func() {
+		Expect(imp.ValidateCreate()).To(Succeed())
+		imp.Spec.TargetNamespace = "backlog"
+		Expect(imp.ValidateCreate()).To(Succeed())
+	})
+
+	It("should reject another target namespace", func() {
+		imp.Spec.TargetNamespace = "kube-system"
+		Expect(apierrors.IsInvalid(imp.ValidateCreate())).To(BeTrue())
+		Expect(apierrors.IsInvalid(imp.ValidateUpdate(imp.DeepCopy()))).To(BeTrue())
+	})
+})
@@ -0,0 +1,170 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultRepoHost is the host of a RepositoryRef without one
+const DefaultRepoHost = "github.com"
+
+// repoPartPattern matches an owner or a repo name
+var repoPartPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// ParseRepoURL normalizes the accepted forms of a repo URL into a RepositoryRef -
+// https://github.com/<owner>/<repo>, with http, www., a .git suffix or a trailing slash,
+// github.com/<owner>/<repo> without a scheme, git@github.com:<owner>/<repo>.git, ssh://git@github.com/<owner>/<repo>.git,
+// and the same forms with a Github Enterprise host. Anything after the repo's name, such as /issues, is rejected
+func ParseRepoURL(raw string) (RepositoryRef, error) {
+	return ParseProviderRepoURL(raw, "")
+}
+
+// ParseProviderRepoURL parses raw as ParseRepoURL does, for a repo of provider (spec.provider, empty when it is unset).
+// A GitLab project can be in nested groups, https://gitlab.com/<group>/<subgroup>/<project>, so on GitLab
+// (see nestedNamespaces) every path segment before the project's is its owner
+func ParseProviderRepoURL(raw string, provider string) (RepositoryRef, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return RepositoryRef{}, fmt.Errorf("the repo URL is empty")
+	}
+	if !strings.Contains(value, "://") {
+		if at := strings.Index(value, "@"); at >= 0 && strings.Contains(value[at:], ":") {
+			value = "ssh://" + value[:at+1] + strings.Replace(value[at+1:], ":", "/", 1) // git@host:owner/repo
+		} else {
+			value = "https://" + value
+		}
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return RepositoryRef{}, fmt.Errorf("malformed repo URL %q: %w", raw, err)
+	}
+	host := strings.ToLower(parsed.Host)
+	switch parsed.Scheme {
+	case "http", "https":
+	case "ssh", "git":
+		host = strings.ToLower(parsed.Hostname()) // the SSH port isn't the API's
+	default:
+		return RepositoryRef{}, fmt.Errorf("malformed repo URL %q: unsupported scheme %s", raw, parsed.Scheme)
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return RepositoryRef{}, fmt.Errorf("malformed repo URL %q: unexpected query or fragment", raw)
+	}
+	if host == "www."+DefaultRepoHost {
+		host = DefaultRepoHost
+	}
+	nested := nestedNamespaces(provider, host)
+	parts := strings.Split(strings.TrimSuffix(strings.Trim(parsed.Path, "/"), ".git"), "/")
+	if len(parts) < 2 || (len(parts) > 2 && !nested) {
+		return RepositoryRef{}, fmt.Errorf("malformed repo URL %q: expected <host>/<owner>/<repo>", raw)
+	}
+	ref := RepositoryRef{Host: host, Owner: strings.Join(parts[:len(parts)-1], "/"), Name: parts[len(parts)-1]}
+	return ref, ref.validate(nested)
+}
+
+// Validate checks the host, owner and name of the repo
+func (r RepositoryRef) Validate() error {
+	return r.validate(false)
+}
+
+// validate checks the host, owner and name of the repo, whose owner is made of nested groups when nested is true
+func (r RepositoryRef) validate(nested bool) error {
+	if r.Host != "" && (strings.ContainsAny(r.Host, "/@?# ") || strings.Trim(r.Host, ".:") == "") {
+		return fmt.Errorf("malformed repo host %q", r.Host)
+	}
+	parts := []string{r.Owner}
+	if nested {
+		parts = strings.Split(r.Owner, "/")
+	}
+	for _, part := range append(parts, r.Name) {
+		if !repoPartPattern.MatchString(part) || part == "." || part == ".." || part == "-" { // GitLab's /-/ starts a page's path
+			return fmt.Errorf("malformed repo owner or name %q", part)
+		}
+	}
+	return nil
+}
+
+// nestedNamespaces tells if the repos of provider on host can be in nested groups, as GitLab projects can -
+// provider is gitlab, or it is unset and host has a gitlab label in its name, as the operator guesses the provider of such a host.
+// A GitLab host named otherwise needs spec.provider set for them
+func nestedNamespaces(provider string, host string) bool {
+	if provider != "" {
+		return provider == ProviderGitlab
+	}
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	for _, label := range strings.Split(strings.ToLower(host), ".") {
+		if label == ProviderGitlab {
+			return true
+		}
+	}
+	return false
+}
+
+// HostOrDefault returns the repo's host, DefaultRepoHost when it is empty
+func (r RepositoryRef) HostOrDefault() string {
+	if r.Host == "" {
+		return DefaultRepoHost
+	}
+	return strings.ToLower(r.Host)
+}
+
+// OwnerRepo returns <owner>/<repo>, the repo's path in the API
+func (r RepositoryRef) OwnerRepo() string {
+	return r.Owner + "/" + r.Name
+}
+
+// URL returns the repo's web URL
+func (r RepositoryRef) URL() string {
+	return "https://" + r.HostOrDefault() + "/" + r.OwnerRepo()
+}
+
+// Key identifies the repo regardless of the case and the form it was given in
+func (r RepositoryRef) Key() string {
+	return strings.ToLower(r.HostOrDefault() + "/" + r.OwnerRepo())
+}
+
+// ResolveRepository returns spec.repository, or spec.repo parsed when it is unset.
+// When both are set they must be the same repo
+func (s *GithubIssueSpec) ResolveRepository() (RepositoryRef, error) {
+	if s.Repository == nil {
+		if s.Repo == "" {
+			return RepositoryRef{}, fmt.Errorf("either repo or repository must be set")
+		}
+		return ParseProviderRepoURL(s.Repo, s.Provider)
+	}
+	ref := *s.Repository
+	ref.Host = ref.HostOrDefault()
+	if err := ref.validate(nestedNamespaces(s.Provider, ref.Host)); err != nil {
+		return RepositoryRef{}, err
+	}
+	if s.Repo != "" {
+		parsed, err := ParseProviderRepoURL(s.Repo, s.Provider)
+		if err != nil {
+			return RepositoryRef{}, err
+		}
+		if parsed.Key() != ref.Key() {
+			return RepositoryRef{}, fmt.Errorf("repo %s and repository %s are different repos", s.Repo, ref.URL())
+		}
+	}
+	return ref, nil
+}
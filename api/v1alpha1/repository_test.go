@@ -0,0 +1,101 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Repo URL parsing", func() {
+	It("should normalize every accepted form", func() {
+		for _, repo := range []string{
+			"https://github.com/razo7/githubissues-operator",
+			"http://github.com/razo7/githubissues-operator",
+			"https://github.com/razo7/githubissues-operator/",
+			"https://github.com/razo7/githubissues-operator.git",
+			"https://www.github.com/razo7/githubissues-operator",
+			"https://GitHub.com/razo7/githubissues-operator",
+			"github.com/razo7/githubissues-operator",
+			"git@github.com:razo7/githubissues-operator.git",
+			"ssh://git@github.com/razo7/githubissues-operator.git",
+			" https://github.com/razo7/githubissues-operator ",
+		} {
+			ref, err := ParseRepoURL(repo)
+			Expect(err).NotTo(HaveOccurred(), repo)
+			Expect(ref).To(Equal(RepositoryRef{Host: "github.com", Owner: "razo7", Name: "githubissues-operator"}), repo)
+		}
+	})
+
+	It("should keep a Github Enterprise host and its port", func() {
+		ref, err := ParseRepoURL("https://github.example.com:8443/team/project")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref).To(Equal(RepositoryRef{Host: "github.example.com:8443", Owner: "team", Name: "project"}))
+		Expect(ref.OwnerRepo()).To(Equal("team/project"))
+		ref, err = ParseRepoURL("git@github.example.com:team/project.git")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref.Host).To(Equal("github.example.com"))
+	})
+
+	It("should take every group of a GitLab project as its owner", func() {
+		ref, err := ParseRepoURL("https://gitlab.com/group/subgroup/project.git")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref).To(Equal(RepositoryRef{Host: "gitlab.com", Owner: "group/subgroup", Name: "project"}))
+		Expect(ref.OwnerRepo()).To(Equal("group/subgroup/project"))
+		ref, err = ParseProviderRepoURL("git@code.example.com:group/subgroup/project.git", ProviderGitlab)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref).To(Equal(RepositoryRef{Host: "code.example.com", Owner: "group/subgroup", Name: "project"}))
+		spec := GithubIssueSpec{Provider: ProviderGitlab, Repository: &RepositoryRef{Host: "code.example.com", Owner: "group/subgroup", Name: "project"}}
+		_, err = spec.ResolveRepository()
+		Expect(err).NotTo(HaveOccurred())
+
+		By("rejecting nested groups elsewhere and a GitLab page's path")
+		for _, repo := range []string{"https://code.example.com/group/subgroup/project", "https://gitlab.com/group/project/-/issues"} {
+			_, err = ParseRepoURL(repo)
+			Expect(err).To(HaveOccurred(), repo)
+		}
+		_, err = ParseProviderRepoURL("https://gitlab.com/group/subgroup/project", ProviderGitea)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should fail on malformed URLs instead of panicking", func() {
+		for _, repo := range []string{
+			"",
+			"github.com",
+			"https://github.com/razo7",
+			"https://github.com/razo7/githubissues-operator/issues/3",
+			"https://github.com/razo7/githubissues-operator#readme",
+			"https://github.com/razo7/../x",
+			"https://github.com/ra zo7/x",
+			"mailto:someone@github.com",
+			"https://%zz/razo7/x",
+		} {
+			_, err := ParseRepoURL(repo)
+			Expect(err).To(HaveOccurred(), repo)
+		}
+	})
+
+	It("should resolve spec.repository with the default host", func() {
+		spec := GithubIssueSpec{Repository: &RepositoryRef{Owner: "razo7", Name: "githubissues-operator"}}
+		ref, err := spec.ResolveRepository()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref.URL()).To(Equal("https://github.com/razo7/githubissues-operator"))
+		Expect(ref.Key()).To(Equal("github.com/razo7/githubissues-operator"))
+		_, err = (&GithubIssueSpec{}).ResolveRepository()
+		Expect(err).To(HaveOccurred())
+	})
+})
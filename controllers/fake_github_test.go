@@ -0,0 +1,250 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	githubApi "github.com/razo7/githubissues-operator/github"
+)
+
+// fakeGithub is an in-memory stand-in for the Github issues REST API, so the suite runs without network access
+type fakeGithub struct {
+	*httptest.Server
+	mu     sync.Mutex
+	tokens map[string]bool
+	repos  map[string]bool
+	issues map[string]map[int]*githubApi.GithubRecieve // repo -> number -> issue
+	locked map[string]bool                             // repo#number -> locked
+	// comments of the issues, by repo#number
+	comments map[string][]githubApi.GithubComment
+	// the ID of the last comment, IDs are unique across issues as on Github
+	lastCommentID int64
+}
+
+// newFakeGithub serves the issues API of repos for requests authorized with token
+func newFakeGithub(token string, repos ...string) *fakeGithub {
+	f := &fakeGithub{tokens: map[string]bool{token: true}, repos: map[string]bool{}, issues: map[string]map[int]*githubApi.GithubRecieve{},
+		locked: map[string]bool{}, comments: map[string][]githubApi.GithubComment{}}
+	for _, repo := range repos {
+		f.repos[repo] = true
+		f.issues[repo] = map[int]*githubApi.GithubRecieve{}
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+// allowToken authorizes requests made with token as well
+func (f *fakeGithub) allowToken(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens[token] = true
+}
+
+// issue returns a copy of the stored issue, or nil if it doesn't exist
+func (f *fakeGithub) issue(repo string, number int) *githubApi.GithubRecieve {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if issue, ok := f.issues[repo][number]; ok {
+		copied := *issue
+		return &copied
+	}
+	return nil
+}
+
+// issueComments returns the comments posted on an issue and whether it is locked
+func (f *fakeGithub) issueComments(repo string, number int) ([]githubApi.GithubComment, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := repo + "#" + strconv.Itoa(number)
+	return append([]githubApi.GithubComment(nil), f.comments[key]...), f.locked[key]
+}
+
+// addIssue files an issue directly, as if it was created outside of the cluster
+func (f *fakeGithub) addIssue(repo string, title string, body string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	number := len(f.issues[repo]) + 1
+	f.issues[repo][number] = &githubApi.GithubRecieve{Title: title, Description: body, State: "open", Number: number}
+	return number
+}
+
+// search returns every issue of the repo in the repo: qualifier of the query, the caller filters them further
+func (f *fakeGithub) search(w http.ResponseWriter, req *http.Request) {
+	found := githubApi.GithubSearch{Items: []githubApi.GithubRecieve{}}
+	for _, term := range strings.Fields(req.URL.Query().Get("q")) {
+		if repo := strings.TrimPrefix(term, "repo:"); repo != term {
+			for _, issue := range f.issues[repo] {
+				found.Items = append(found.Items, *issue)
+			}
+		}
+	}
+	found.TotalCount = len(found.Items)
+	_ = json.NewEncoder(w).Encode(found)
+}
+
+// list returns all the issues of repo in one page, oldest first
+func (f *fakeGithub) list(w http.ResponseWriter, repo string) {
+	issues := []githubApi.GithubRecieve{}
+	for number := 1; number <= len(f.issues[repo]); number++ {
+		if issue, ok := f.issues[repo][number]; ok {
+			issues = append(issues, *issue)
+		}
+	}
+	_ = json.NewEncoder(w).Encode(issues)
+}
+
+// edit sets the labels, assignees and milestone sent in data
+func (f *fakeGithub) edit(issue *githubApi.GithubRecieve, data githubApi.GithubSend) {
+	if data.Labels != nil {
+		issue.Labels = nil
+		for _, name := range data.Labels {
+			issue.Labels = append(issue.Labels, githubApi.GithubLabel{Name: name})
+		}
+	}
+	if data.Assignees != nil {
+		issue.Assignees = nil
+		for _, login := range data.Assignees {
+			issue.Assignees = append(issue.Assignees, githubApi.GithubUser{Login: login})
+		}
+	}
+	if data.Milestone != nil {
+		issue.Milestone = &githubApi.GithubMilestone{Number: *data.Milestone}
+	}
+}
+
+// editComment edits or deletes the comment id of repo
+func (f *fakeGithub) editComment(w http.ResponseWriter, method string, repo string, id string, body string) {
+	for key, comments := range f.comments {
+		if !strings.HasPrefix(key, repo+"#") {
+			continue
+		}
+		for i, comment := range comments {
+			if strconv.FormatInt(comment.ID, 10) != id {
+				continue
+			}
+			switch method {
+			case "PATCH":
+				comments[i].Body = body
+				_ = json.NewEncoder(w).Encode(comments[i])
+			case "DELETE":
+				f.comments[key] = append(comments[:i:i], comments[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.WriteHeader(http.StatusForbidden)
+			}
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (f *fakeGithub) serve(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.tokens[strings.TrimPrefix(req.Header.Get("Authorization"), "token ")] {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if req.URL.Path == "/search/issues" {
+		f.search(w, req)
+		return
+	}
+	// expected paths are /repos/<owner>/<repo>/issues[/<number>[/lock|/comments]]
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/repos/"), "/")
+	if len(parts) < 3 || parts[2] != "issues" || !f.repos[parts[0]+"/"+parts[1]] {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	repo := parts[0] + "/" + parts[1]
+	var data githubApi.GithubSend
+	if req.Method == "POST" || req.Method == "PATCH" || req.Method == "PUT" {
+		if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if len(parts) == 3 {
+		if req.Method == "GET" {
+			f.list(w, repo)
+			return
+		}
+		if req.Method != "POST" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		issue := &githubApi.GithubRecieve{Title: data.Title, Description: data.Body, State: "open", Number: len(f.issues[repo]) + 1}
+		f.edit(issue, data)
+		f.issues[repo][issue.Number] = issue
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(issue)
+		return
+	}
+	if len(parts) == 5 && parts[3] == "comments" { // /repos/<owner>/<repo>/issues/comments/<id>
+		f.editComment(w, req.Method, repo, parts[4], data.Body)
+		return
+	}
+	number, _ := strconv.Atoi(parts[3])
+	issue, ok := f.issues[repo][number]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if len(parts) == 5 {
+		key := repo + "#" + parts[3]
+		switch {
+		case parts[4] == "lock" && req.Method == "PUT":
+			f.locked[key] = true
+			w.WriteHeader(http.StatusNoContent)
+		case parts[4] == "comments" && req.Method == "POST":
+			f.lastCommentID++
+			comment := githubApi.GithubComment{ID: f.lastCommentID, Body: data.Body}
+			f.comments[key] = append(f.comments[key], comment)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(comment)
+		case parts[4] == "comments" && req.Method == "GET":
+			_ = json.NewEncoder(w).Encode(append([]githubApi.GithubComment{}, f.comments[key]...))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+		return
+	}
+	switch req.Method {
+	case "GET":
+	case "PATCH":
+		if data.Title != "" {
+			issue.Title = data.Title
+		}
+		if data.Body != "" {
+			issue.Description = data.Body
+		}
+		if data.State != "" {
+			issue.State = data.State
+			issue.StateReason = data.StateReason
+		}
+		f.edit(issue, data)
+	default:
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(issue)
+}
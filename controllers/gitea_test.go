@@ -0,0 +1,270 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+	githubApi "github.com/razo7/githubissues-operator/github"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// giteaStandIn mimics the issue API of a Gitea server with the repo site/backlog and its labels bug (ID 1) and ops (ID 2)
+type giteaStandIn struct {
+	mu       sync.Mutex
+	issues   map[int]map[string]interface{}
+	comments map[int64]map[string]interface{}
+	nextID   int64
+	auth     []string
+}
+
+func (g *giteaStandIn) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	const repoPath = githubApi.GiteaAPIPath + "/repos/site/backlog"
+	labels := map[float64]string{1: "bug", 2: "ops"}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.auth = append(g.auth, req.Header.Get("Authorization"))
+	var data map[string]interface{}
+	_ = json.NewDecoder(req.Body).Decode(&data)
+	setLabels := func(issue map[string]interface{}, ids []interface{}) {
+		set := []map[string]interface{}{}
+		for _, id := range ids {
+			set = append(set, map[string]interface{}{"id": id, "name": labels[id.(float64)]})
+		}
+		issue["labels"] = set
+	}
+	reply := func(code int, body interface{}) {
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(body)
+	}
+	path := strings.TrimPrefix(req.URL.Path, repoPath)
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case path == "/labels":
+		reply(http.StatusOK, []map[string]interface{}{{"id": 1, "name": "bug"}, {"id": 2, "name": "ops"}})
+	case path == "/issues" && req.Method == "GET":
+		Expect(req.URL.Query().Get("type")).To(Equal("issues"))
+		reply(http.StatusOK, []interface{}{})
+	case path == "/issues" && req.Method == "POST":
+		number := len(g.issues) + 1
+		issue := map[string]interface{}{"number": number, "title": data["title"], "body": data["body"], "state": "open",
+			"html_url": "https://gitea.site-a.internal/site/backlog/issues/" + strconv.Itoa(number)}
+		ids, _ := data["labels"].([]interface{})
+		setLabels(issue, ids)
+		g.issues[number] = issue
+		reply(http.StatusCreated, issue)
+	case len(parts) == 3 && parts[1] == "comments" && req.Method == "PATCH":
+		id, _ := strconv.ParseInt(parts[2], 10, 64)
+		g.comments[id]["body"] = data["body"]
+		reply(http.StatusOK, g.comments[id])
+	case len(parts) == 3 && parts[1] == "comments" && req.Method == "DELETE":
+		id, _ := strconv.ParseInt(parts[2], 10, 64)
+		delete(g.comments, id)
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) >= 2 && parts[0] == "issues":
+		number, _ := strconv.Atoi(parts[1])
+		issue, ok := g.issues[number]
+		if !ok {
+			reply(http.StatusNotFound, map[string]string{"message": "issue does not exist"})
+			return
+		}
+		switch {
+		case len(parts) == 2 && req.Method == "PATCH": // Gitea responds 201 to an edit
+			for _, key := range []string{"title", "body", "state"} {
+				if value, ok := data[key]; ok {
+					issue[key] = value
+				}
+			}
+			reply(http.StatusCreated, issue)
+		case len(parts) == 2:
+			reply(http.StatusOK, issue)
+		case parts[2] == "labels" && req.Method == "PUT":
+			ids, _ := data["labels"].([]interface{})
+			setLabels(issue, ids)
+			reply(http.StatusOK, issue["labels"])
+		case parts[2] == "comments" && req.Method == "POST":
+			comment := map[string]interface{}{"id": g.nextID, "body": data["body"]}
+			g.comments[g.nextID] = comment
+			g.nextID++
+			reply(http.StatusCreated, comment)
+		case parts[2] == "comments":
+			listed := []interface{}{}
+			for id := int64(900); id < g.nextID; id++ {
+				if comment, ok := g.comments[id]; ok {
+					listed = append(listed, comment)
+				}
+			}
+			reply(http.StatusOK, listed)
+		}
+	default:
+		reply(http.StatusNotFound, map[string]string{"message": "not found"})
+	}
+}
+
+var _ = Describe("Gitea repos", func() {
+	const (
+		SecretName = "gitea-credentials"
+		IssueName  = "gitea-githubissue"
+		Namespace  = "default"
+	)
+	var (
+		gitea      *giteaStandIn
+		server     *httptest.Server
+		fakeClient client.Client
+		r          *GithubIssueReconciler
+		hosts      *githubApi.Hosts
+	)
+
+	BeforeEach(func() {
+		gitea = &giteaStandIn{issues: map[int]map[string]interface{}{}, comments: map[int64]map[string]interface{}{}, nextID: 900}
+		server = httptest.NewServer(gitea)
+		// the provider is guessed from the host's name
+		var err error
+		hosts, err = githubApi.NewHosts(githubApi.HostsConfig{Hosts: []githubApi.HostConfig{
+			{Host: "gitea.site-a.internal", APIURL: server.URL + githubApi.GiteaAPIPath},
+		}}, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: SecretName, Namespace: Namespace},
+			Data:       map[string][]byte{githubApi.DefaultSecretKey: []byte("gitea-credential")},
+		}
+		githubi := &trainingv1alpha1.GithubIssue{
+			ObjectMeta: metav1.ObjectMeta{Name: IssueName, Namespace: Namespace},
+			Spec: trainingv1alpha1.GithubIssueSpec{
+				Repo:                 "https://gitea.site-a.internal/site/backlog",
+				Title:                "Air-gapped issue",
+				Description:          "Hi from a disconnected site",
+				Labels:               []string{"bug"},
+				CredentialsSecretRef: &trainingv1alpha1.SecretKeyReference{Name: SecretName},
+			},
+		}
+		fakeClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret, githubi).Build()
+		r = &GithubIssueReconciler{
+			Client:   fakeClient,
+			Log:      zap.New(zap.WriteTo(GinkgoWriter)),
+			Scheme:   scheme.Scheme,
+			Recorder: record.NewFakeRecorder(100),
+			Hosts:    hosts,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	reconcile := func() trainingv1alpha1.GithubIssue {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: IssueName, Namespace: Namespace}})
+		Expect(err).NotTo(HaveOccurred())
+		stored := trainingv1alpha1.GithubIssue{}
+		if err := fakeClient.Get(context.Background(), types.NamespacedName{Name: IssueName, Namespace: Namespace}, &stored); err != nil {
+			Expect(client.IgnoreNotFound(err)).To(Succeed())
+		}
+		return stored
+	}
+
+	It("should create, update, comment on and close a Gitea issue", func() {
+		By("creating the issue with its labels")
+		reconcile() // registers the finalizer
+		githubi := reconcile()
+		Expect(githubi.Status.Number).To(Equal(1))
+		Expect(githubi.Status.Labels).To(ConsistOf("bug"))
+		Expect(gitea.issues[1]["body"]).To(ContainSubstring("Hi from a disconnected site"))
+
+		By("updating the title and labels and posting a comment")
+		githubi.Spec.Title = "Air-gapped issue, triaged"
+		githubi.Spec.Labels = []string{"bug", "ops"}
+		githubi.Spec.Comments = []trainingv1alpha1.IssueComment{{Key: "triage", Body: "triaged by the ops team"}}
+		Expect(fakeClient.Update(context.Background(), &githubi)).To(Succeed())
+		githubi = reconcile()
+		Expect(githubi.Status.Title).To(Equal("Air-gapped issue, triaged"))
+		Expect(githubi.Status.Labels).To(ConsistOf("bug", "ops"))
+		Expect(githubi.Status.Comments).To(HaveLen(1))
+		Expect(gitea.comments[githubi.Status.Comments[0].ID]["body"]).To(ContainSubstring("triaged by the ops team"))
+		synced := meta.FindStatusCondition(githubi.Status.Conditions, trainingv1alpha1.ConditionSynced)
+		Expect(synced.Status).To(Equal(metav1.ConditionTrue))
+
+		By("editing the comment")
+		githubi.Spec.Comments[0].Body = "triaged again"
+		Expect(fakeClient.Update(context.Background(), &githubi)).To(Succeed())
+		githubi = reconcile()
+		Expect(gitea.comments).To(HaveLen(1))
+		Expect(gitea.comments[githubi.Status.Comments[0].ID]["body"]).To(ContainSubstring("triaged again"))
+
+		By("closing the issue once the GithubIssue is deleted")
+		Expect(fakeClient.Delete(context.Background(), &githubi)).To(Succeed())
+		// the fake client's status update already removes the finalizer, so the GithubIssue may be gone for the update after it
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: IssueName, Namespace: Namespace}})
+		Expect(client.IgnoreNotFound(err)).To(Succeed())
+		Expect(gitea.issues[1]["state"]).To(Equal("closed"))
+		Expect(fakeClient.Get(context.Background(), types.NamespacedName{Name: IssueName, Namespace: Namespace}, &githubi)).NotTo(Succeed())
+
+		for _, auth := range gitea.auth {
+			Expect(auth).To(Equal("token gitea-credential"))
+		}
+	})
+
+	It("should remove every label once spec.labels is an empty list", func() {
+		reconcile() // registers the finalizer
+		githubi := reconcile()
+		Expect(githubi.Status.Labels).To(ConsistOf("bug"))
+
+		// the fake client drops an empty list from the stored spec, so the issue is synced directly
+		githubi.Spec.Labels = []string{}
+		host, _ := hosts.Lookup("gitea.site-a.internal")
+		githubi, err, updated := githubApi.GetIssue(host.Client, githubi, "site/backlog", "gitea-credential", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated).To(BeTrue())
+		Expect(githubi.Status.Labels).To(BeEmpty())
+		Expect(gitea.issues[1]["labels"]).To(BeEmpty())
+
+		_, err, updated = githubApi.GetIssue(host.Client, githubi, "site/backlog", "gitea-credential", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated).To(BeFalse())
+	})
+
+	It("should reject a label the repo doesn't have as Github does", func() {
+		githubi := trainingv1alpha1.GithubIssue{}
+		Expect(fakeClient.Get(context.Background(), types.NamespacedName{Name: IssueName, Namespace: Namespace}, &githubi)).To(Succeed())
+		githubi.Spec.Labels = []string{"no-such-label"}
+		Expect(fakeClient.Update(context.Background(), &githubi)).To(Succeed())
+		reconcile()
+		githubi = reconcile()
+		Expect(githubi.Status.Number).To(BeZero())
+		Expect(gitea.issues).To(BeEmpty())
+		synced := meta.FindStatusCondition(githubi.Status.Conditions, trainingv1alpha1.ConditionSynced)
+		Expect(synced.Reason).To(Equal(trainingv1alpha1.ReasonValidationFailed))
+	})
+})
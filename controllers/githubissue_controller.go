@@ -22,31 +22,77 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
 
 	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
 	githubApi "github.com/razo7/githubissues-operator/github"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
+// credentialsSecretRefIndex is the field index of GithubIssues by spec.credentialsSecretRef.name
+const credentialsSecretRefIndex = ".spec.credentialsSecretRef.name"
+
+// hostCredentialsIndex is the field index of the GithubIssues without spec.credentialsSecretRef by their repo's host,
+// whose credentials Secret in the hosts configuration they use
+const hostCredentialsIndex = ".spec.repository.host"
+
+// ownedIssueIndex is the field index of GithubIssues by the repo and number of the issue they manage
+const ownedIssueIndex = ".status.ownedIssue"
+
 // GithubIssueReconciler reconciles a GithubIssue object
 type GithubIssueReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// GithubClient makes the Github API calls, e.g. githubApi.NewClient for Github.com or a fake one for testing
+	GithubClient githubApi.Client
+	// GitlabClient makes the GitLab API calls of the repos on gitlab.com, nil leaves them unsupported
+	GitlabClient githubApi.Client
+	// Recorder records the Events of GithubIssues
+	Recorder record.EventRecorder
+	// AppTokens mints installation tokens for GithubIssues authenticated as a Github App
+	AppTokens *githubApi.AppTokenSource
+	// Hosts are the Github Enterprise, GitLab, Gitea and Jira hosts, GithubClient and AppTokens serve the repos on github.com
+	Hosts *githubApi.Hosts
+	// WebhookEvents enqueues the GithubIssues a WebhookReceiver got a delivery for, nil when webhooks are disabled
+	WebhookEvents chan event.GenericEvent
+	// ResyncPeriod is how often every GithubIssue is compared with its issue, DefaultResyncPeriod when zero
+	ResyncPeriod time.Duration
 }
 
+// DefaultResyncPeriod is the resync period of GithubIssues when no webhook delivers their changes
+const DefaultResyncPeriod = 60 * time.Second
+
 //+kubebuilder:rbac:groups=training.githubissues,resources=githubissues,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=training.githubissues,resources=githubissues/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=training.githubissues,resources=githubissues/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=redhat.com,resources=githubissues/finalizers,verbs=get;create;update;patch;delete
 // For watching the resource and implementing finalizers ->
 //  https://developers.redhat.com/blog/2020/09/11/5-tips-for-developing-kubernetes-operators-with-the-new-operator-sdk#:~:text=adding%20rbac%20permissions%20with%20go
@@ -79,24 +125,71 @@ func (r *GithubIssueReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		}
 		return result, err
 	}
+	fetched := *githubi.DeepCopy() // the object as fetched, for finding status changes and recording failures
 	if githubi.Status.Number > 0 {
 		firstRun = false // chnaged into false once it has a number (ID)
 	}
-	ownerRepo := strings.Split(githubi.Spec.Repo, "github.com/")[1] // extract the repo's username, and repo's name from the repo's url
+	// examine DeletionTimestamp to determine if object is under deletion
+	deleting := !githubi.ObjectMeta.DeletionTimestamp.IsZero()
+	if deleting && (githubi.Spec.DeletionPolicy == trainingv1alpha1.DeletionPolicyOrphan || githubi.Status.Number == 0) {
+		// nothing to do on Github, so neither the repo nor the credentials are needed - the Secret may be gone already,
+		// e.g. when the namespace is torn down
+		if controllerutil.ContainsFinalizer(&githubi, githubApi.FinalizerName) {
+			controllerutil.RemoveFinalizer(&githubi, githubApi.FinalizerName)
+			if err := r.Update(ctx, &githubi); err != nil {
+				logger.Error(err, "Can't update reconcile - for unregister finalizer")
+				return result, err
+			}
+		}
+		logger.Info("Successful release", "number", githubi.Status.Number)
+		return result, nil
+	}
+	repo, err := githubi.Spec.ResolveRepository() // the repo's host, username and name from spec.repository or the repo's url
+	if err != nil {
+		logger.Error(err, "Invalid repo")
+		return r.invalidRepo(ctx, fetched, githubi, trainingv1alpha1.ReasonInvalidRepo, err)
+	}
+	ownerRepo := repo.OwnerRepo()
+	provider := githubApi.ProviderFor(githubi.Spec.Provider, repo.HostOrDefault(), r.Hosts)
+	gc, host, err := r.clientFor(repo, provider)
+	if err != nil {
+		logger.Error(err, "Unknown host")
+		return r.invalidRepo(ctx, fetched, githubi, trainingv1alpha1.ReasonUnknownHost, err)
+	}
 	// register finalizer once the CR has been created
-	if githubi.Status.LastUpdateTimestamp == "" {
+	if githubi.Status.LastUpdateTimestamp == "" && !deleting {
 		if !githubApi.ContainsString(githubi.GetFinalizers(), githubApi.FinalizerName) {
 			controllerutil.AddFinalizer(&githubi, githubApi.FinalizerName) // registering our finalizer.
 			githubi.Status.LastUpdateTimestamp = time.Now().String()
 		}
 	} // if - register finalizer
 
-	// examine DeletionTimestamp to determine if object is under deletion
-	if !githubi.ObjectMeta.DeletionTimestamp.IsZero() {
+	// resolve the token on every reconcile, so a rotated Secret is used right away
+	token, watched, err := r.resolveToken(ctx, githubi, host, provider, ownerRepo)
+	if !watched && err == nil {
+		r.Recorder.Eventf(&githubi, corev1.EventTypeWarning, trainingv1alpha1.ReasonCredentialsNotWatched,
+			"The credentials Secret isn't labelled %s=true, so a rotated token is used only from the next resync", trainingv1alpha1.CredentialsSecretLabel)
+	}
+	if err != nil {
+		err = githubApi.RedactError(err)
+		logger.Error(err, "Can't resolve Github credentials")
+		githubApi.SetCondition(&githubi, trainingv1alpha1.ConditionCredentialsValid, metav1.ConditionFalse, trainingv1alpha1.ReasonCredentialsUnavailable, err.Error())
+		r.Recorder.Event(&githubi, corev1.EventTypeWarning, trainingv1alpha1.ReasonCredentialsUnavailable, err.Error())
+		r.recordFailure(ctx, fetched, githubi)
+		return result, err
+	}
+
+	if deleting {
 		// The object is being deleted
-		if githubi, err = githubApi.DeleteIssue(githubi, ownerRepo); err != nil {
+		closing := githubApi.ContainsString(githubi.GetFinalizers(), githubApi.FinalizerName)
+		if githubi, err = githubApi.DeleteIssue(gc, githubi, ownerRepo, token); err != nil {
+			err = githubApi.RedactError(err, token) // the credential never reaches the logs, Events or status
 			logger.Error(err, "Closing issue")
-			return result, err
+			r.recordFailure(ctx, fetched, githubi)
+			return r.requeueFailure(&githubi, err)
+		}
+		if closing {
+			r.Recorder.Eventf(&githubi, corev1.EventTypeNormal, trainingv1alpha1.ReasonClosed, "Closed issue #%d of %s", githubi.Status.Number, ownerRepo)
 		}
 		logger.Info("Successful close", "number", githubi.Status.Number)
 	} // if we need to delete the issue
@@ -109,51 +202,384 @@ func (r *GithubIssueReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		logger.Info("Before creation/update", "number", githubi.Status.Number, "state", githubi.Status.State)
 	}
 
-	if githubi.Status.State != githubApi.Fail_Repo { // if the repo is valid
+	if !deleting { // if the issue isn't closed for deletion
 
 		if githubi.Status.Number == 0 { // Zero = uninitialized field
-			if githubi, err, _ = githubApi.GetIssue(githubi, ownerRepo, "POST"); err != nil {
-				logger.Error(err, "Creating Issue")
-				return result, err
+			// adopt an existing issue (spec.issueNumber or spec.adoptByTitle) instead of creating one
+			var adopt int
+			if githubi, adopt, err = githubApi.FindIssueToAdopt(gc, githubi, ownerRepo, token); err != nil {
+				err = githubApi.RedactError(err, token)
+				logger.Error(err, "Finding Issue to adopt")
+				r.recordFailure(ctx, fetched, githubi)
+				return r.requeueFailure(&githubi, err)
+			}
+			if adopt > 0 {
+				owner, err := r.issueOwner(ctx, githubi, repo, adopt)
+				if err != nil {
+					return result, err
+				}
+				if owner != "" {
+					err = fmt.Errorf("issue %d of %s is already managed by GithubIssue %s", adopt, ownerRepo, owner)
+					logger.Error(err, "Refusing adoption")
+					githubApi.SetCondition(&githubi, trainingv1alpha1.ConditionSynced, metav1.ConditionFalse, trainingv1alpha1.ReasonAlreadyOwned, err.Error())
+					r.Recorder.Event(&githubi, corev1.EventTypeWarning, trainingv1alpha1.ReasonAlreadyOwned, err.Error())
+					r.recordFailure(ctx, fetched, githubi)
+					return ctrl.Result{RequeueAfter: r.resyncPeriod()}, nil // retrying won't help until the other GithubIssue is gone
+				}
+				githubi.Status.Number = adopt
+				if githubi, err, _ = githubApi.GetIssue(gc, githubi, ownerRepo, token, "GET"); err != nil {
+					err = githubApi.RedactError(err, token)
+					logger.Error(err, "Adopting Issue")
+					r.recordFailure(ctx, fetched, githubi)
+					return r.requeueFailure(&githubi, err)
+				}
+				githubi.Status.LastUpdateTimestamp = time.Now().String()
+				r.Recorder.Eventf(&githubi, corev1.EventTypeNormal, trainingv1alpha1.ReasonAdopted, "Adopted issue #%d of %s", githubi.Status.Number, ownerRepo)
+				logger.Info("Successful adoption", "number", githubi.Status.Number, "state", githubi.Status.State)
+			} else {
+				if githubi, err, _ = githubApi.GetIssue(gc, githubi, ownerRepo, token, "POST"); err != nil {
+					err = githubApi.RedactError(err, token)
+					logger.Error(err, "Creating Issue")
+					r.recordFailure(ctx, fetched, githubi)
+					return r.requeueFailure(&githubi, err)
+				}
+				r.Recorder.Eventf(&githubi, corev1.EventTypeNormal, trainingv1alpha1.ReasonCreated, "Created issue #%d in %s", githubi.Status.Number, ownerRepo)
 			}
 			logger.Info("Successful creation", "number", githubi.Status.Number, "state", githubi.Status.State)
 
 		} else {
 			// if githubi.Spec.Description != issue.Description { // update the description (if needed).
-			if githubi, err, success = githubApi.GetIssue(githubi, ownerRepo, "GET"); err != nil {
+			if githubi, err, success = githubApi.GetIssue(gc, githubi, ownerRepo, token, "GET"); err != nil {
+				err = githubApi.RedactError(err, token)
 				logger.Error(err, "Updating Issue")
-				return result, err
+				r.recordFailure(ctx, fetched, githubi)
+				return r.requeueFailure(&githubi, err)
 			}
 			if success {
+				r.Recorder.Eventf(&githubi, corev1.EventTypeNormal, trainingv1alpha1.ReasonUpdated, "Updated issue #%d of %s to match the spec", githubi.Status.Number, ownerRepo)
 				logger.Info("Successful update", "number", githubi.Status.Number, "description", githubi.Spec.Description)
 			}
 		} // else
+
+		// create, edit and prune the comments of spec.comments
+		if githubi.Status.Number > 0 && (len(githubi.Spec.Comments) > 0 || len(githubi.Status.Comments) > 0) {
+			var commented bool
+			if githubi, commented, err = githubApi.SyncComments(gc, githubi, ownerRepo, token); err != nil {
+				err = githubApi.RedactError(err, token)
+				logger.Error(err, "Syncing comments")
+				r.recordFailure(ctx, fetched, githubi)
+				return r.requeueFailure(&githubi, err)
+			}
+			if commented {
+				r.Recorder.Eventf(&githubi, corev1.EventTypeNormal, trainingv1alpha1.ReasonCommented, "Synced the comments of issue #%d of %s", githubi.Status.Number, ownerRepo)
+				logger.Info("Successful comments sync", "number", githubi.Status.Number, "comments", len(githubi.Status.Comments))
+			}
+		}
 	} else {
 		// remove our finalizer from the list and update it.
 		controllerutil.RemoveFinalizer(&githubi, githubApi.FinalizerName)
 	}
 
-	// Update the client status or the whole client (for register/unregister finalizer)
-	if firstRun {
-		if err := r.Client.Status().Update(ctx, &githubi); err != nil { // Update Vs. Patch -> https://sdk.operatorframework.io/docs/building-operators/golang/references/client/#status
-			logger.Error(err, "Can't update Client's status")
-			return result, err
+	if githubi.Status.State != githubApi.Fail_Repo {
+		githubi.Status.ObservedGeneration = githubi.Generation
+	}
+	// raise an Event once a ReportOnly field drifts, or drifts differently
+	if drifted := meta.FindStatusCondition(githubi.Status.Conditions, trainingv1alpha1.ConditionDrifted); drifted != nil && drifted.Status == metav1.ConditionTrue {
+		if before := meta.FindStatusCondition(fetched.Status.Conditions, trainingv1alpha1.ConditionDrifted); before == nil || before.Status != drifted.Status || before.Message != drifted.Message {
+			r.Recorder.Event(&githubi, corev1.EventTypeWarning, trainingv1alpha1.ReasonDrifted, drifted.Message)
+		}
+	}
+	githubApi.SetReadyCondition(&githubi)
+
+	// A GithubIssue being deleted only needs its finalizer removed - the response of a status update
+	// would bring back the finalizer as it is stored, and the CR would never go away
+	finalized := !equality.Semantic.DeepEqual(fetched.Finalizers, githubi.Finalizers)
+	if deleting {
+		if finalized {
+			if err := r.Update(ctx, &githubi); err != nil {
+				logger.Error(err, "Can't update reconcile - for unregister finalizer")
+				return result, err
+			}
 		}
+		logger.Info("End reconcile", "number", githubi.Status.Number, "state", githubi.Status.State)
+		return result, nil
 	}
-	if firstRun || githubi.Status.State == "closed" {
+	// Update the whole client (once its finalizers changed), then the client status (once it has changed).
+	// The response of an update replaces githubi, so the status is kept aside meanwhile
+	status := githubi.Status.DeepCopy()
+	if finalized {
 		if err := r.Update(ctx, &githubi); err != nil {
-			logger.Error(err, "Can't update reconcile - for register/unregister finalizer")
+			logger.Error(err, "Can't update reconcile - for register finalizer")
+			return result, err
+		}
+		githubi.Status = *status
+	}
+	if firstRun || !equality.Semantic.DeepEqual(fetched.Status, githubi.Status) {
+		if err := r.Client.Status().Update(ctx, &githubi); err != nil { // Update Vs. Patch -> https://sdk.operatorframework.io/docs/building-operators/golang/references/client/#status
+			logger.Error(err, "Can't update Client's status")
 			return result, err
 		}
 	}
 
 	logger.Info("End reconcile", "number", githubi.Status.Number, "state", githubi.Status.State)
-	return ctrl.Result{RequeueAfter: 60 * time.Second}, nil // tweak the resync period to every 1 minute.
+	return ctrl.Result{RequeueAfter: r.resyncPeriod()}, nil
 } // Reconcile
 
+// requeueFailure records a Warning Event of githubi's failed Github call and returns the result of the reconcile -
+// a rate limited GithubIssue is requeued once the limit resets, instead of retrying with backoff against a budget which is known to be exhausted.
+// A terminal failure (a missing repo, rejected credentials or invalid fields) isn't retried with backoff but on the next resync,
+// as a repo made private for a while or a rotated token may recover on their own - or sooner, once its spec or credentials Secret
+// changes or a webhook delivers a change of its issue. Any other failure is retried with backoff
+func (r *GithubIssueReconciler) requeueFailure(githubi *trainingv1alpha1.GithubIssue, err error) (ctrl.Result, error) {
+	r.Recorder.Event(githubi, corev1.EventTypeWarning, githubApi.FailureReason(err), err.Error())
+	var limited *githubApi.RateLimitError
+	if errors.As(err, &limited) {
+		return ctrl.Result{RequeueAfter: limited.RetryAfter(time.Now())}, nil
+	}
+	if githubApi.IsTerminal(err) {
+		return ctrl.Result{RequeueAfter: r.resyncPeriod()}, nil
+	}
+	return ctrl.Result{}, err
+}
+
+// resyncPeriod returns ResyncPeriod, or DefaultResyncPeriod when it is unset
+func (r *GithubIssueReconciler) resyncPeriod() time.Duration {
+	if r.ResyncPeriod <= 0 {
+		return DefaultResyncPeriod
+	}
+	return r.ResyncPeriod
+}
+
+// recordFailure persists the conditions of a failed reconcile and the Fail_Repo state on top of the fetched status,
+// the rest of the status is left as is so the failed call is retried on the next reconcile, which sets the issue's state again
+func (r *GithubIssueReconciler) recordFailure(ctx context.Context, fetched trainingv1alpha1.GithubIssue, githubi trainingv1alpha1.GithubIssue) {
+	before := fetched.Status
+	fetched.Status.State = githubApi.Fail_Repo
+	fetched.Status.Conditions = githubi.Status.Conditions
+	githubApi.SetReadyCondition(&fetched)
+	if equality.Semantic.DeepEqual(before, fetched.Status) {
+		return
+	}
+	if err := r.Client.Status().Update(ctx, &fetched); err != nil {
+		r.Log.Error(err, "Can't record the failure in Client's status", "githubissue", fetched.Name)
+	}
+}
+
+// invalidRepo reports a spec whose repo can't be parsed or whose host isn't configured, it isn't retried until the spec changes.
+// A GithubIssue being deleted has no issue the operator can reach, so its finalizer is removed
+func (r *GithubIssueReconciler) invalidRepo(ctx context.Context, fetched trainingv1alpha1.GithubIssue, githubi trainingv1alpha1.GithubIssue, reason string, err error) (ctrl.Result, error) {
+	if !githubi.ObjectMeta.DeletionTimestamp.IsZero() {
+		controllerutil.RemoveFinalizer(&githubi, githubApi.FinalizerName)
+		return ctrl.Result{}, r.Update(ctx, &githubi)
+	}
+	githubApi.SetCondition(&githubi, trainingv1alpha1.ConditionRepoAccessible, metav1.ConditionFalse, reason, err.Error())
+	githubApi.SetCondition(&githubi, trainingv1alpha1.ConditionSynced, metav1.ConditionFalse, reason, err.Error())
+	r.Recorder.Event(&githubi, corev1.EventTypeWarning, reason, err.Error())
+	r.recordFailure(ctx, fetched, githubi)
+	return ctrl.Result{}, nil
+}
+
+// issueOwner returns the namespace/name of another GithubIssue which manages issue number of repo, or an empty string
+func (r *GithubIssueReconciler) issueOwner(ctx context.Context, githubi trainingv1alpha1.GithubIssue, repo trainingv1alpha1.RepositoryRef, number int) (string, error) {
+	githubis := trainingv1alpha1.GithubIssueList{}
+	if err := r.List(ctx, &githubis, client.MatchingFields{ownedIssueIndex: ownedIssueKey(repo, number)}); err != nil {
+		return "", err
+	}
+	for _, other := range githubis.Items {
+		if other.UID != githubi.UID {
+			return other.Namespace + "/" + other.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// ownedIssueKey identifies issue number of repo
+func ownedIssueKey(repo trainingv1alpha1.RepositoryRef, number int) string {
+	return repo.Key() + "#" + strconv.Itoa(number)
+}
+
+// clientFor returns the client of repo's host for provider, with the host's configuration when it is a configured
+// (Github Enterprise, GitLab, Gitea or Jira) host
+func (r *GithubIssueReconciler) clientFor(repo trainingv1alpha1.RepositoryRef, provider string) (githubApi.Client, *githubApi.Host, error) {
+	if host, ok := r.Hosts.Lookup(repo.HostOrDefault()); ok {
+		if host.Config.Provider != provider {
+			return nil, nil, fmt.Errorf("host %s is a %s host, not %s", repo.HostOrDefault(), host.Config.Provider, provider)
+		}
+		return host.Client, host, nil
+	}
+	switch {
+	case provider == trainingv1alpha1.ProviderGithub && repo.HostOrDefault() == trainingv1alpha1.DefaultRepoHost:
+		return r.GithubClient, nil, nil
+	case provider == trainingv1alpha1.ProviderGitlab && repo.HostOrDefault() == githubApi.DefaultGitlabHost && r.GitlabClient != nil:
+		return r.GitlabClient, nil, nil
+	}
+	return nil, nil, fmt.Errorf("%s host %s isn't in the operator's hosts configuration", provider, repo.HostOrDefault())
+}
+
+// ForgetIssue drops issue number of repo from the GraphQL batches of its host's client, so its next reconcile fetches it with REST
+func (r *GithubIssueReconciler) ForgetIssue(repo trainingv1alpha1.RepositoryRef, number int) {
+	gc, _, err := r.clientFor(repo, trainingv1alpha1.ProviderGithub)
+	if err != nil {
+		return
+	}
+	if rest, ok := gc.(*githubApi.RestClient); ok {
+		rest.Batches.Forget(repo.OwnerRepo(), number)
+	}
+}
+
+// resolveToken returns the token stored in spec.credentialsSecretRef. When it is unset, the token is taken from the
+// credentials Secret of the repo's host, or the operator's global token for github.com.
+// Credentials holding a Github App ID and private key are exchanged for an installation token of ownerRepo instead,
+// which works on Github only
+// watched is false when the token is of a Secret without CredentialsSecretLabel, whose rotation is picked up on the next resync only
+func (r *GithubIssueReconciler) resolveToken(ctx context.Context, githubi trainingv1alpha1.GithubIssue, host *githubApi.Host, provider string, ownerRepo string) (token string, watched bool, err error) {
+	appTokens := r.AppTokens
+	if host != nil {
+		appTokens = host.AppTokens
+	}
+	if provider != trainingv1alpha1.ProviderGithub {
+		appTokens = nil
+	}
+	if ref := githubi.Spec.CredentialsSecretRef; ref != nil {
+		return r.secretToken(ctx, types.NamespacedName{Name: ref.Name, Namespace: githubi.Namespace}, ref.Key, appTokens, ownerRepo)
+	}
+	if host != nil {
+		ref := host.Config.CredentialsSecret
+		if ref == nil {
+			return "", false, fmt.Errorf("host %s has no credentials secret, set spec.credentialsSecretRef", host.Config.Host)
+		}
+		return r.secretToken(ctx, types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}, ref.Key, appTokens, ownerRepo)
+	}
+	if provider != trainingv1alpha1.ProviderGithub {
+		return "", false, fmt.Errorf("the operator has no global %s token, set spec.credentialsSecretRef", provider)
+	}
+	if appID, privateKey := githubApi.DefaultAppCredentials(); appID != "" {
+		token, err := appToken(appTokens, appID, privateKey, ownerRepo)
+		return token, true, err
+	}
+	return githubApi.DefaultToken(), true, nil
+}
+
+// secretToken returns the token under key (DefaultSecretKey if empty) of the Secret name, or an App installation token
+// when the Secret holds Github App credentials. watched tells if the Secret is labelled CredentialsSecretLabel
+func (r *GithubIssueReconciler) secretToken(ctx context.Context, name types.NamespacedName, key string, appTokens *githubApi.AppTokenSource, ownerRepo string) (string, bool, error) {
+	secret := corev1.Secret{}
+	if err := r.Get(ctx, name, &secret); err != nil {
+		return "", false, fmt.Errorf("credentials secret %s: %w", name.Name, err)
+	}
+	watched := secret.Labels[trainingv1alpha1.CredentialsSecretLabel] == "true"
+	if appID, ok := secret.Data[githubApi.AppIDKey]; ok {
+		token, err := appToken(appTokens, string(appID), secret.Data[githubApi.AppPrivateKeyKey], ownerRepo)
+		return token, watched, err
+	}
+	if key == "" {
+		key = githubApi.DefaultSecretKey
+	}
+	value, ok := secret.Data[key]
+	if !ok || len(value) == 0 {
+		return "", watched, fmt.Errorf("credentials secret %s has no key %s", name.Name, key)
+	}
+	return string(value), watched, nil
+}
+
+// appToken returns an installation token of the Github App for ownerRepo
+func appToken(appTokens *githubApi.AppTokenSource, appID string, privateKey []byte, ownerRepo string) (string, error) {
+	if appTokens == nil {
+		return "", fmt.Errorf("Github App credentials are set but there is no Github App token source for this repo")
+	}
+	return appTokens.Token(strings.TrimSpace(appID), privateKey, ownerRepo)
+}
+
+// issuesForSecret maps a Secret into the GithubIssues in its namespace whose spec.credentialsSecretRef points at it,
+// and into the GithubIssues without one of the hosts whose credentials Secret it is in the hosts configuration
+func (r *GithubIssueReconciler) issuesForSecret(obj client.Object) []reconcile.Request {
+	githubis := trainingv1alpha1.GithubIssueList{}
+	if err := r.List(context.Background(), &githubis, client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{credentialsSecretRefIndex: obj.GetName()}); err != nil {
+		r.Log.Error(err, "Can't list GithubIssues for secret", "secret", obj.GetName())
+		return nil
+	}
+	items := githubis.Items
+	for _, host := range r.Hosts.UsingSecret(obj.GetNamespace(), obj.GetName()) {
+		hostIssues := trainingv1alpha1.GithubIssueList{}
+		if err := r.List(context.Background(), &hostIssues, client.MatchingFields{hostCredentialsIndex: host}); err != nil {
+			r.Log.Error(err, "Can't list GithubIssues for the secret of host", "secret", obj.GetName(), "host", host)
+			continue
+		}
+		items = append(items, hostIssues.Items...)
+	}
+	requests := make([]reconcile.Request, 0, len(items))
+	for _, githubi := range items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: githubi.Name, Namespace: githubi.Namespace}})
+	}
+	return requests
+}
+
+// hostCredentialsKeys returns the hostCredentialsIndex keys of obj, the host of its repo when it has no spec.credentialsSecretRef
+func hostCredentialsKeys(obj client.Object) []string {
+	githubi := obj.(*trainingv1alpha1.GithubIssue)
+	if githubi.Spec.CredentialsSecretRef != nil {
+		return nil
+	}
+	repo, err := githubi.Spec.ResolveRepository()
+	if err != nil {
+		return nil
+	}
+	return []string{repo.HostOrDefault()}
+}
+
+// SecretCacheOptions sets the manager's cache up for the GithubIssue controller: Secrets are read uncached, and only those labelled
+// CredentialsSecretLabel are cached, for the watch which reconciles their GithubIssues once they are rotated -
+// so the manager doesn't cache every Secret of the cluster
+func SecretCacheOptions(options ctrl.Options) ctrl.Options {
+	options.NewCache = cache.BuilderWithOptions(cache.Options{SelectorsByObject: cache.SelectorsByObject{
+		&corev1.Secret{}: {Label: labels.SelectorFromSet(labels.Set{trainingv1alpha1.CredentialsSecretLabel: "true"})},
+	}})
+	options.ClientDisableCacheFor = append(options.ClientDisableCacheFor, &corev1.Secret{})
+	return options
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *GithubIssueReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	// index GithubIssues by their credentials Secret, so a Secret change enqueues only the GithubIssues using it
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &trainingv1alpha1.GithubIssue{}, credentialsSecretRefIndex, func(obj client.Object) []string {
+		githubi := obj.(*trainingv1alpha1.GithubIssue)
+		if githubi.Spec.CredentialsSecretRef == nil {
+			return nil
+		}
+		return []string{githubi.Spec.CredentialsSecretRef.Name}
+	}); err != nil {
+		return err
+	}
+	// index GithubIssues by their repo's host, so a change of a host's credentials Secret enqueues the GithubIssues using it
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &trainingv1alpha1.GithubIssue{}, hostCredentialsIndex, hostCredentialsKeys); err != nil {
+		return err
+	}
+	// index GithubIssues by the issue they manage, for refusing to adopt an issue twice and routing webhook deliveries
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &trainingv1alpha1.GithubIssue{}, ownedIssueIndex, func(obj client.Object) []string {
+		githubi := obj.(*trainingv1alpha1.GithubIssue)
+		if githubi.Status.Number == 0 {
+			return nil
+		}
+		repo, err := githubi.Spec.ResolveRepository()
+		if err != nil {
+			return nil
+		}
+		return []string{ownedIssueKey(repo, githubi.Status.Number)}
+	}); err != nil {
+		return err
+	}
+	// report the managed issues and the deletions waiting on the finalizer when the metrics are scraped
+	if err := metrics.Registry.Register(&issueCollector{client: mgr.GetClient(), log: r.Log}); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			return err
+		}
+	}
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&trainingv1alpha1.GithubIssue{}).
-		Complete(r)
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.issuesForSecret))
+	if r.WebhookEvents != nil {
+		builder = builder.Watches(&source.Channel{Source: r.WebhookEvents}, &handler.EnqueueRequestForObject{})
+	}
+	return builder.Complete(r)
 }
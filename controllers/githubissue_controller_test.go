@@ -17,9 +17,15 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"time"
 
@@ -27,23 +33,37 @@ import (
 	. "github.com/onsi/gomega"
 	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
 	githubApi "github.com/razo7/githubissues-operator/github"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 )
 
 var _ = Describe("GithubIssue controller", func() {
 
 	// Define utility constants for object names and testing timeouts/durations and intervals.
 	const (
-		GoodGithubIssueName   = "good-githubissue"
-		BadGithubIssueName    = "bad-githubissue"
-		DeleteGithubIssueName = "delete-githubissue"
-		GithubIssueNamespace  = "default"
-		JobName               = "test-job"
-		RepoName              = "razo7/githubissues-operator"
-		RepoURL               = "https://github.com/razo7/githubissues-operator"
-		Timeout               = time.Second * 4
-		Interval              = time.Millisecond * 250
+		GoodGithubIssueName    = "good-githubissue"
+		BadGithubIssueName     = "bad-githubissue"
+		DeleteGithubIssueName  = "delete-githubissue"
+		SecretGithubIssueName  = "secret-githubissue"
+		PolicyGithubIssueName  = "policy-githubissue"
+		OrphanGithubIssueName  = "orphan-githubissue"
+		AdoptGithubIssueName   = "adopt-githubissue"
+		InvalidGithubIssueName = "invalid-githubissue"
+		CredentialsSecretName  = "team-credentials"
+		OrphanSecretName       = "orphan-credentials"
+		RotatedGithubIssueName = "rotated-githubissue"
+		RotatedSecretName      = "rotated-credentials"
+		GithubIssueNamespace   = "default"
+		JobName                = "test-job"
+		RepoName               = "razo7/githubissues-operator"
+		RepoURL                = "https://github.com/razo7/githubissues-operator"
+		Timeout                = time.Second * 4
+		Interval               = time.Millisecond * 250
 	)
 	var (
 		githubIssue              trainingv1alpha1.GithubIssue
@@ -55,6 +75,7 @@ var _ = Describe("GithubIssue controller", func() {
 		i = 0
 		ctx = context.Background()
 		token := os.Getenv("GIT_TOKEN_GI")
+		var restClient *githubApi.RestClient
 		BeforeEach(func() {
 			goodGithubIssueLookupKey = types.NamespacedName{Name: GoodGithubIssueName, Namespace: GithubIssueNamespace}
 			githubIssue = trainingv1alpha1.GithubIssue{
@@ -77,6 +98,7 @@ var _ = Describe("GithubIssue controller", func() {
 				},
 			} // githubIssue
 			Expect(k8sClient).To(Not(BeNil()))
+			restClient = githubApi.NewClient(fakeGithubServer.URL, fakeGithubServer.Client())
 			i++
 			githubIssue.Spec.Title = "Test " + fmt.Sprint(i)
 			err := k8sClient.Create(ctx, &githubIssue)
@@ -104,6 +126,11 @@ var _ = Describe("GithubIssue controller", func() {
 			It("should succeed ", func() {
 				By("use a good repo")
 				Expect(k8sClient.Get(ctx, goodGithubIssueLookupKey, &githubIssue)).Should(Succeed())
+				By("wait for the Ready condition")
+				Eventually(func() bool {
+					err := k8sClient.Get(ctx, goodGithubIssueLookupKey, &githubIssue)
+					return err == nil && meta.IsStatusConditionTrue(githubIssue.Status.Conditions, trainingv1alpha1.ConditionReady)
+				}, Timeout, Interval).Should(BeTrue())
 			}) //it - test 1
 
 			// It("should fail due to a bad repo ", func() {
@@ -149,34 +176,39 @@ var _ = Describe("GithubIssue controller", func() {
 		When("we test creating and deleting - REST API", func() {
 			It("Post and Close - should succeed", func() {
 				var issue githubApi.GithubRecieve // Storing the github issue from Github website
-				resp, body, err := githubApi.GithubAPIcall(RepoName, githubIssue.Spec.Title, githubIssue.Spec.Description, githubIssue.Status.Number, token, "POST")
+				issueData := githubApi.GithubSend{Title: githubIssue.Spec.Title, Body: githubIssue.Spec.Description}
+				resp, body, err := restClient.GithubAPIcall(RepoName, issueData, githubIssue.Status.Number, token, "POST")
 
 				Expect(err).To(BeNil())
 				Expect(resp.StatusCode).To(Equal(201))
 				Expect(json.Unmarshal(body, &issue)).To(BeNil())
 				_ = json.Unmarshal(body, &issue)
 				githubIssue.Status.Number = issue.Number
-				resp, _, err = githubApi.GithubAPIcall(RepoName, githubIssue.Spec.Title, githubIssue.Spec.Description, githubIssue.Status.Number, token, "CLOSE")
+				resp, _, err = restClient.GithubAPIcall(RepoName, issueData, githubIssue.Status.Number, token, "CLOSE")
 				Expect(err).To(BeNil())
 				Expect(resp.StatusCode).To(Equal(200))
 			}) // it - test 3
 		}) // when - 2
 		When("we test update Github.com - Bad REST API", func() {
+			var issueData githubApi.GithubSend
+			BeforeEach(func() {
+				issueData = githubApi.GithubSend{Title: githubIssue.Spec.Title, Body: githubIssue.Spec.Description}
+			})
 
 			It("shouldn't succeed due to a bad token", func() {
-				resp, _, err := githubApi.GithubAPIcall(RepoName, githubIssue.Spec.Title, githubIssue.Spec.Description, githubIssue.Status.Number, token+"somthing", "POST")
+				resp, _, err := restClient.GithubAPIcall(RepoName, issueData, githubIssue.Status.Number, token+"somthing", "POST")
 				Expect(err).To(BeNil())
 				Expect(resp.StatusCode).To(Equal(401))
 			}) // it - test 4
 
 			It("shouldn't succeed due to a bad API call", func() {
 
-				resp, _, err := githubApi.GithubAPIcall(RepoName, githubIssue.Spec.Title, githubIssue.Spec.Description, githubIssue.Status.Number, token, "NOTHING")
+				resp, _, err := restClient.GithubAPIcall(RepoName, issueData, githubIssue.Status.Number, token, "NOTHING")
 				Expect(err).To(BeNil())
 				Expect(resp.StatusCode).To(Equal(403))
 			}) // it - test 5
 			It("shouldn't succeed due to a bad repo", func() {
-				resp, _, err := githubApi.GithubAPIcall(RepoName+"1", githubIssue.Spec.Title, githubIssue.Spec.Description, githubIssue.Status.Number, token, "POST")
+				resp, _, err := restClient.GithubAPIcall(RepoName+"1", issueData, githubIssue.Status.Number, token, "POST")
 				Expect(err).To(BeNil())
 				Expect(resp.StatusCode).To(Equal(404))
 			}) // it - test 6
@@ -227,6 +259,408 @@ var _ = Describe("GithubIssue controller", func() {
 				}, Timeout, Interval).ShouldNot(Succeed())
 			}) // it - test 8
 		}) // when - 5
+
+		When("we close an issue with spec.state", func() {
+			It("should close the issue and keep the CR", func() {
+				Eventually(func() error { // retry on conflicts with the reconciler's status updates
+					if err := k8sClient.Get(ctx, goodGithubIssueLookupKey, &githubIssue); err != nil {
+						return err
+					}
+					githubIssue.Spec.State = trainingv1alpha1.StateClosed
+					githubIssue.Spec.StateReason = "completed"
+					return k8sClient.Update(ctx, &githubIssue)
+				}, Timeout, Interval).Should(Succeed())
+				Eventually(func() string {
+					if issue := fakeGithubServer.issue(RepoName, githubIssue.Status.Number); issue != nil {
+						return issue.State
+					}
+					return ""
+				}, Timeout, Interval).Should(Equal(trainingv1alpha1.StateClosed))
+				Eventually(func() string {
+					_ = k8sClient.Get(ctx, goodGithubIssueLookupKey, &githubIssue)
+					return githubIssue.Status.State
+				}, Timeout, Interval).Should(Equal(trainingv1alpha1.StateClosed))
+
+				By("reopen it")
+				Eventually(func() error {
+					if err := k8sClient.Get(ctx, goodGithubIssueLookupKey, &githubIssue); err != nil {
+						return err
+					}
+					githubIssue.Spec.State = trainingv1alpha1.StateOpen
+					githubIssue.Spec.StateReason = "reopened"
+					return k8sClient.Update(ctx, &githubIssue)
+				}, Timeout, Interval).Should(Succeed())
+				Eventually(func() string {
+					return fakeGithubServer.issue(RepoName, githubIssue.Status.Number).State
+				}, Timeout, Interval).Should(Equal(trainingv1alpha1.StateOpen))
+			}) // it - test 10
+		}) // when - 7
+
+		When("we declare comments in spec.comments", func() {
+			It("should post, edit and prune them once", func() {
+				commentBodies := func() []string {
+					comments, _ := fakeGithubServer.issueComments(RepoName, githubIssue.Status.Number)
+					var bodies []string
+					for _, comment := range comments {
+						bodies = append(bodies, comment.Body)
+					}
+					return bodies
+				}
+				Eventually(func() error {
+					if err := k8sClient.Get(ctx, goodGithubIssueLookupKey, &githubIssue); err != nil {
+						return err
+					}
+					githubIssue.Spec.Comments = []trainingv1alpha1.IssueComment{
+						{Key: "deploy", Body: "deploy of v1.4 finished"}, {Key: "rollback", Body: "no rollback needed"}}
+					githubIssue.Spec.PruneComments = true
+					return k8sClient.Update(ctx, &githubIssue)
+				}, Timeout, Interval).Should(Succeed())
+				Eventually(func() int {
+					_ = k8sClient.Get(ctx, goodGithubIssueLookupKey, &githubIssue)
+					return len(githubIssue.Status.Comments)
+				}, Timeout, Interval).Should(Equal(2))
+				Expect(commentBodies()).To(ConsistOf(ContainSubstring("deploy of v1.4 finished"), ContainSubstring("no rollback needed")))
+
+				By("editing one comment and removing the other")
+				Eventually(func() error {
+					if err := k8sClient.Get(ctx, goodGithubIssueLookupKey, &githubIssue); err != nil {
+						return err
+					}
+					githubIssue.Spec.Comments = []trainingv1alpha1.IssueComment{{Key: "deploy", Body: "deploy of v1.5 finished"}}
+					return k8sClient.Update(ctx, &githubIssue)
+				}, Timeout, Interval).Should(Succeed())
+				Eventually(commentBodies, Timeout, Interval).Should(ConsistOf(ContainSubstring("deploy of v1.5 finished")))
+				Consistently(commentBodies, time.Second, Interval).Should(HaveLen(1))
+			}) // it - test 17
+		}) // when - comments
+
+		When("we delete an issue with a deletion policy", func() {
+			It("should comment, close and keep the finalizer until done", func() {
+				policyGithubIssueLookupKey := types.NamespacedName{Name: PolicyGithubIssueName, Namespace: GithubIssueNamespace}
+				policyGithubIssue := trainingv1alpha1.GithubIssue{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      PolicyGithubIssueName,
+						Namespace: GithubIssueNamespace,
+					},
+					Spec: trainingv1alpha1.GithubIssueSpec{
+						Repo:           RepoURL,
+						Title:          "K8s policy Issue",
+						Description:    "an issue closed with a comment",
+						DeletionPolicy: trainingv1alpha1.DeletionPolicyCommentAndClose,
+						ClosingComment: "Bye",
+					},
+				}
+				Expect(k8sClient.Create(ctx, &policyGithubIssue)).Should(Succeed())
+				Eventually(func() bool {
+					err := k8sClient.Get(ctx, policyGithubIssueLookupKey, &policyGithubIssue)
+					return err == nil && policyGithubIssue.Status.Number > 0
+				}, Timeout, Interval).Should(BeTrue())
+				Expect(k8sClient.Delete(ctx, &policyGithubIssue)).Should(Succeed())
+				Eventually(func() error {
+					return k8sClient.Get(ctx, policyGithubIssueLookupKey, &policyGithubIssue)
+				}, Timeout, Interval).ShouldNot(Succeed())
+				comments, _ := fakeGithubServer.issueComments(RepoName, policyGithubIssue.Status.Number)
+				Expect(comments).To(HaveLen(1))
+				Expect(comments[0].Body).To(Equal("Bye"))
+				Expect(fakeGithubServer.issue(RepoName, policyGithubIssue.Status.Number).State).To(Equal("closed"))
+			}) // it - test 11
+
+			It("should leave the issue open with Orphan, even once its credentials secret is gone", func() {
+				const SecretToken = "orphan-token"
+				secret := corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: OrphanSecretName, Namespace: GithubIssueNamespace},
+					Data:       map[string][]byte{githubApi.DefaultSecretKey: []byte(SecretToken)},
+				}
+				fakeGithubServer.allowToken(SecretToken)
+				Expect(k8sClient.Create(ctx, &secret)).Should(Succeed())
+				orphanGithubIssueLookupKey := types.NamespacedName{Name: OrphanGithubIssueName, Namespace: GithubIssueNamespace}
+				orphanGithubIssue := trainingv1alpha1.GithubIssue{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      OrphanGithubIssueName,
+						Namespace: GithubIssueNamespace,
+					},
+					Spec: trainingv1alpha1.GithubIssueSpec{
+						Repo:                 RepoURL,
+						Title:                "K8s orphan Issue",
+						Description:          "an issue left open on deletion",
+						DeletionPolicy:       trainingv1alpha1.DeletionPolicyOrphan,
+						CredentialsSecretRef: &trainingv1alpha1.SecretKeyReference{Name: OrphanSecretName},
+					},
+				}
+				Expect(k8sClient.Create(ctx, &orphanGithubIssue)).Should(Succeed())
+				Eventually(func() bool {
+					err := k8sClient.Get(ctx, orphanGithubIssueLookupKey, &orphanGithubIssue)
+					return err == nil && orphanGithubIssue.Status.Number > 0 &&
+						githubApi.ContainsString(orphanGithubIssue.Finalizers, githubApi.FinalizerName)
+				}, Timeout, Interval).Should(BeTrue())
+				Expect(k8sClient.Delete(ctx, &secret)).Should(Succeed())
+				Expect(k8sClient.Delete(ctx, &orphanGithubIssue)).Should(Succeed())
+				Eventually(func() error {
+					return k8sClient.Get(ctx, orphanGithubIssueLookupKey, &orphanGithubIssue)
+				}, Timeout, Interval).ShouldNot(Succeed())
+				Expect(fakeGithubServer.issue(RepoName, orphanGithubIssue.Status.Number).State).To(Equal(trainingv1alpha1.StateOpen))
+			}) // it - test 18
+		}) // when - 8
+
+		When("we adopt an existing issue", func() {
+			It("should manage it and refuse a second adoption", func() {
+				number := fakeGithubServer.addIssue(RepoName, "K8s adopted Issue", "filed by hand")
+				adoptGithubIssueLookupKey := types.NamespacedName{Name: AdoptGithubIssueName, Namespace: GithubIssueNamespace}
+				adoptGithubIssue := trainingv1alpha1.GithubIssue{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      AdoptGithubIssueName,
+						Namespace: GithubIssueNamespace,
+					},
+					Spec: trainingv1alpha1.GithubIssueSpec{
+						Repo:         RepoURL,
+						Title:        "K8s adopted Issue",
+						Description:  "managed from K8s",
+						AdoptByTitle: true,
+					},
+				}
+				Expect(k8sClient.Create(ctx, &adoptGithubIssue)).Should(Succeed())
+				Eventually(func() int {
+					_ = k8sClient.Get(ctx, adoptGithubIssueLookupKey, &adoptGithubIssue)
+					return adoptGithubIssue.Status.Number
+				}, Timeout, Interval).Should(Equal(number))
+				Eventually(func() string {
+					return fakeGithubServer.issue(RepoName, number).Description
+				}, Timeout, Interval).Should(HavePrefix("managed from K8s"))
+
+				By("adopt the same issue again")
+				secondLookupKey := types.NamespacedName{Name: AdoptGithubIssueName + "-2", Namespace: GithubIssueNamespace}
+				second := trainingv1alpha1.GithubIssue{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      secondLookupKey.Name,
+						Namespace: GithubIssueNamespace,
+					},
+					Spec: trainingv1alpha1.GithubIssueSpec{
+						Repo:        RepoURL,
+						Title:       "K8s adopted Issue",
+						Description: "managed from K8s",
+						IssueNumber: number,
+					},
+				}
+				Expect(k8sClient.Create(ctx, &second)).Should(Succeed())
+				Eventually(func() string {
+					_ = k8sClient.Get(ctx, secondLookupKey, &second)
+					if synced := meta.FindStatusCondition(second.Status.Conditions, trainingv1alpha1.ConditionSynced); synced != nil {
+						return synced.Reason
+					}
+					return ""
+				}, Timeout, Interval).Should(Equal(trainingv1alpha1.ReasonAlreadyOwned))
+				Expect(second.Status.Number).To(Equal(0))
+
+				Expect(k8sClient.Delete(ctx, &second)).Should(Succeed())
+				Expect(k8sClient.Delete(ctx, &adoptGithubIssue)).Should(Succeed())
+				Eventually(func() error {
+					return k8sClient.Get(ctx, adoptGithubIssueLookupKey, &adoptGithubIssue)
+				}, Timeout, Interval).ShouldNot(Succeed())
+			}) // it - test 12
+		}) // when - 9
+
+		When("Github delivers a webhook", func() {
+			It("should enqueue only the GithubIssue managing the issue", func() {
+				secret := []byte("webhook-secret")
+				events := make(chan event.GenericEvent, 10)
+				var forgotten []int
+				receiver := &WebhookReceiver{Client: k8sClient, Log: ctrl.Log.WithName("webhook-suite"), Secret: secret, Events: events,
+					Forget: func(repo trainingv1alpha1.RepositoryRef, number int) { forgotten = append(forgotten, number) }}
+				deliver := func(number int, signature string) int {
+					payload := []byte(fmt.Sprintf(`{"action":"edited","issue":{"number":%d},"repository":{"full_name":"%s","html_url":"%s"}}`,
+						number, RepoName, RepoURL))
+					if signature == "" {
+						mac := hmac.New(sha256.New, secret)
+						mac.Write(payload)
+						signature = githubApi.SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+					}
+					req := httptest.NewRequest("POST", WebhookPath, bytes.NewReader(payload))
+					req.Header.Set("X-GitHub-Event", githubApi.IssuesEvent)
+					req.Header.Set("X-Hub-Signature-256", signature)
+					resp := httptest.NewRecorder()
+					receiver.ServeHTTP(resp, req)
+					return resp.Code
+				}
+				By("deliver an event of the managed issue")
+				Eventually(func() int {
+					return deliver(githubIssue.Status.Number, "")
+				}, Timeout, Interval).Should(Equal(http.StatusNoContent))
+				Eventually(events, Timeout, Interval).Should(Receive(WithTransform(func(e event.GenericEvent) string {
+					return e.Object.GetName()
+				}, Equal(GoodGithubIssueName))))
+				By("deliver an event of an unmanaged issue and a forged one")
+				Expect(deliver(githubIssue.Status.Number+1000, "")).To(Equal(http.StatusNoContent))
+				Expect(deliver(githubIssue.Status.Number, githubApi.SignaturePrefix+"00")).To(Equal(http.StatusUnauthorized))
+				Consistently(events, time.Second, Interval).ShouldNot(Receive())
+				Expect(forgotten).To(ContainElement(githubIssue.Status.Number)) // the forged delivery forgets nothing
+				Expect(forgotten[len(forgotten)-1]).To(Equal(githubIssue.Status.Number + 1000))
+			}) // it - test 13
+		}) // when - 10
+
+		When("the repo can't be parsed", func() {
+			It("should report InvalidRepo and delete the GithubIssue right away", func() {
+				invalidGithubIssueLookupKey := types.NamespacedName{Name: InvalidGithubIssueName, Namespace: GithubIssueNamespace}
+				invalidGithubIssue := trainingv1alpha1.GithubIssue{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:       InvalidGithubIssueName,
+						Namespace:  GithubIssueNamespace,
+						Finalizers: []string{githubApi.FinalizerName},
+					},
+					Spec: trainingv1alpha1.GithubIssueSpec{
+						Repo:        RepoURL + "/issues/1",
+						Title:       "K8s invalid Issue",
+						Description: "a repo URL with a trailing path",
+					},
+				}
+				Expect(k8sClient.Create(ctx, &invalidGithubIssue)).Should(Succeed())
+				Eventually(func() string {
+					_ = k8sClient.Get(ctx, invalidGithubIssueLookupKey, &invalidGithubIssue)
+					if accessible := meta.FindStatusCondition(invalidGithubIssue.Status.Conditions, trainingv1alpha1.ConditionRepoAccessible); accessible != nil {
+						return accessible.Reason
+					}
+					return ""
+				}, Timeout, Interval).Should(Equal(trainingv1alpha1.ReasonInvalidRepo))
+				Expect(invalidGithubIssue.Status.Number).To(Equal(0))
+				Expect(k8sClient.Delete(ctx, &invalidGithubIssue)).Should(Succeed())
+				Eventually(func() error {
+					return k8sClient.Get(ctx, invalidGithubIssueLookupKey, &invalidGithubIssue)
+				}, Timeout, Interval).ShouldNot(Succeed())
+			}) // it - test 14
+
+			It("should report UnknownHost for a Github Enterprise host which isn't configured", func() {
+				invalidGithubIssueLookupKey := types.NamespacedName{Name: InvalidGithubIssueName, Namespace: GithubIssueNamespace}
+				invalidGithubIssue := trainingv1alpha1.GithubIssue{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      InvalidGithubIssueName,
+						Namespace: GithubIssueNamespace,
+					},
+					Spec: trainingv1alpha1.GithubIssueSpec{
+						Repository:  &trainingv1alpha1.RepositoryRef{Host: "github.example.com", Owner: "team", Name: "project"},
+						Title:       "K8s Enterprise Issue",
+						Description: "a repo on an unknown host",
+					},
+				}
+				Expect(k8sClient.Create(ctx, &invalidGithubIssue)).Should(Succeed())
+				Eventually(func() string {
+					_ = k8sClient.Get(ctx, invalidGithubIssueLookupKey, &invalidGithubIssue)
+					if accessible := meta.FindStatusCondition(invalidGithubIssue.Status.Conditions, trainingv1alpha1.ConditionRepoAccessible); accessible != nil {
+						return accessible.Reason
+					}
+					return ""
+				}, Timeout, Interval).Should(Equal(trainingv1alpha1.ReasonUnknownHost))
+				Expect(k8sClient.Delete(ctx, &invalidGithubIssue)).Should(Succeed())
+				Eventually(func() error {
+					return k8sClient.Get(ctx, invalidGithubIssueLookupKey, &invalidGithubIssue)
+				}, Timeout, Interval).ShouldNot(Succeed())
+			}) // it - test 15
+
+			It("should record an Event for the created issue", func() {
+				Eventually(func() []string {
+					events := corev1.EventList{}
+					if err := k8sClient.List(ctx, &events, client.InNamespace(GithubIssueNamespace)); err != nil {
+						return nil
+					}
+					var reasons []string
+					for _, e := range events.Items {
+						if e.InvolvedObject.Name == GoodGithubIssueName {
+							reasons = append(reasons, e.Reason)
+						}
+					}
+					return reasons
+				}, Timeout, Interval).Should(ContainElement(trainingv1alpha1.ReasonCreated))
+			}) // it - test 16
+		}) // when - 11
+
+		When("we use a credentials secret", func() {
+			It("should create the issue with the secret's token", func() {
+				const SecretToken = "team-token"
+				secret := corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: CredentialsSecretName, Namespace: GithubIssueNamespace},
+					Data:       map[string][]byte{githubApi.DefaultSecretKey: []byte(SecretToken)},
+				}
+				fakeGithubServer.allowToken(SecretToken)
+				Expect(k8sClient.Create(ctx, &secret)).Should(Succeed())
+				secretGithubIssueLookupKey := types.NamespacedName{Name: SecretGithubIssueName, Namespace: GithubIssueNamespace}
+				secretGithubIssue := trainingv1alpha1.GithubIssue{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      SecretGithubIssueName,
+						Namespace: GithubIssueNamespace,
+					},
+					Spec: trainingv1alpha1.GithubIssueSpec{
+						Repo:                 RepoURL,
+						Title:                "K8s secret Issue",
+						Description:          "an issue filed with the team's token",
+						CredentialsSecretRef: &trainingv1alpha1.SecretKeyReference{Name: CredentialsSecretName},
+					},
+				}
+				Expect(k8sClient.Create(ctx, &secretGithubIssue)).Should(Succeed())
+				Eventually(func() bool {
+					err := k8sClient.Get(ctx, secretGithubIssueLookupKey, &secretGithubIssue)
+					return err == nil && secretGithubIssue.Status.Number > 0
+				}, Timeout, Interval).Should(BeTrue())
+				Expect(fakeGithubServer.issue(RepoName, secretGithubIssue.Status.Number)).NotTo(BeNil())
+				By("warning that the unlabelled Secret isn't watched")
+				Eventually(func() []string {
+					events := corev1.EventList{}
+					if err := k8sClient.List(ctx, &events, client.InNamespace(GithubIssueNamespace)); err != nil {
+						return nil
+					}
+					var reasons []string
+					for _, e := range events.Items {
+						if e.InvolvedObject.Name == SecretGithubIssueName {
+							reasons = append(reasons, e.Reason)
+						}
+					}
+					return reasons
+				}, Timeout, Interval).Should(ContainElement(trainingv1alpha1.ReasonCredentialsNotWatched))
+				Expect(k8sClient.Delete(ctx, &secretGithubIssue)).Should(Succeed())
+				Eventually(func() error {
+					return k8sClient.Get(ctx, secretGithubIssueLookupKey, &secretGithubIssue)
+				}, Timeout, Interval).ShouldNot(Succeed())
+				Expect(k8sClient.Delete(ctx, &secret)).Should(Succeed())
+			}) // it - test 9
+
+			It("should reconcile with the rotated token of a labelled secret right away", func() {
+				const StaleToken, RotatedToken = "stale-token", "rotated-token"
+				secret := corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: RotatedSecretName, Namespace: GithubIssueNamespace,
+						Labels: map[string]string{trainingv1alpha1.CredentialsSecretLabel: "true"}},
+					Data: map[string][]byte{githubApi.DefaultSecretKey: []byte(StaleToken)},
+				}
+				fakeGithubServer.allowToken(RotatedToken)
+				Expect(k8sClient.Create(ctx, &secret)).Should(Succeed())
+				rotatedLookupKey := types.NamespacedName{Name: RotatedGithubIssueName, Namespace: GithubIssueNamespace}
+				rotatedGithubIssue := trainingv1alpha1.GithubIssue{
+					ObjectMeta: metav1.ObjectMeta{Name: RotatedGithubIssueName, Namespace: GithubIssueNamespace},
+					Spec: trainingv1alpha1.GithubIssueSpec{
+						Repo:                 RepoURL,
+						Title:                "K8s rotated Issue",
+						Description:          "an issue filed once the team's token is rotated",
+						CredentialsSecretRef: &trainingv1alpha1.SecretKeyReference{Name: RotatedSecretName},
+					},
+				}
+				Expect(k8sClient.Create(ctx, &rotatedGithubIssue)).Should(Succeed())
+				By("failing with the stale token")
+				Eventually(func() bool {
+					err := k8sClient.Get(ctx, rotatedLookupKey, &rotatedGithubIssue)
+					return err == nil && meta.IsStatusConditionFalse(rotatedGithubIssue.Status.Conditions, trainingv1alpha1.ConditionReady)
+				}, Timeout, Interval).Should(BeTrue())
+
+				By("creating the issue once the Secret is rotated, well before the next resync")
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: RotatedSecretName, Namespace: GithubIssueNamespace}, &secret)).Should(Succeed())
+				secret.Data[githubApi.DefaultSecretKey] = []byte(RotatedToken)
+				Expect(k8sClient.Update(ctx, &secret)).Should(Succeed())
+				Eventually(func() bool {
+					err := k8sClient.Get(ctx, rotatedLookupKey, &rotatedGithubIssue)
+					return err == nil && rotatedGithubIssue.Status.Number > 0
+				}, Timeout, Interval).Should(BeTrue())
+				Expect(k8sClient.Delete(ctx, &rotatedGithubIssue)).Should(Succeed())
+				Eventually(func() error {
+					return k8sClient.Get(ctx, rotatedLookupKey, &rotatedGithubIssue)
+				}, Timeout, Interval).ShouldNot(Succeed())
+				Expect(k8sClient.Delete(ctx, &secret)).Should(Succeed())
+			}) // it - test 9b
+		}) // when - 6
 	}) //context
 
 })
@@ -0,0 +1,337 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-logr/logr"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+	githubApi "github.com/razo7/githubissues-operator/github"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// defaultNameTemplate names the imported GithubIssues when spec.nameTemplate is empty
+const defaultNameTemplate = "{{.Repo}}-{{.Number}}"
+
+// invalidNameChars are the runs of characters a GithubIssue's name can't have
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// GithubIssueImportReconciler reconciles a GithubIssueImport object - it lists the matching issues of the repo
+// and creates a GithubIssue for each issue no GithubIssue manages yet, with status.number set so it is adopted
+type GithubIssueImportReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// Recorder records the Events of GithubIssueImports
+	Recorder record.EventRecorder
+	// Issues resolves the client and the credentials of an import's repo, as it does for the repo's GithubIssues
+	Issues *GithubIssueReconciler
+}
+
+// importedName holds the fields of an issue which spec.nameTemplate can use
+type importedName struct {
+	Owner  string
+	Repo   string
+	Number int
+	Key    string
+	Title  string
+}
+
+//+kubebuilder:rbac:groups=training.githubissues,resources=githubissueimports,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=training.githubissues,resources=githubissueimports/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=training.githubissues,resources=githubissueimports/finalizers,verbs=update
+
+// Reconcile imports the issues matching a GithubIssueImport's spec once its generation changes.
+// An issue which a GithubIssue already manages, or whose name a GithubIssue already has, is skipped,
+// so changing the spec (e.g. spec.since) imports the new matching issues only
+func (r *GithubIssueImportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("githubissueimport", req.NamespacedName)
+	imp := trainingv1alpha1.GithubIssueImport{}
+	if err := r.Get(ctx, req.NamespacedName, &imp); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if imp.Status.ObservedGeneration == imp.Generation {
+		return ctrl.Result{}, nil
+	}
+	fetched := *imp.DeepCopy()
+
+	// the repo's client and credentials are resolved as for a GithubIssue of the repo in the import's namespace
+	source := trainingv1alpha1.GithubIssue{
+		ObjectMeta: metav1.ObjectMeta{Name: imp.Name, Namespace: imp.Namespace},
+		Spec: trainingv1alpha1.GithubIssueSpec{
+			Repo:                 imp.Spec.Repo,
+			Provider:             imp.Spec.Provider,
+			CredentialsSecretRef: imp.Spec.CredentialsSecretRef,
+		},
+	}
+	repo, err := source.Spec.ResolveRepository()
+	if err != nil {
+		logger.Error(err, "Invalid repo")
+		return r.importFailed(ctx, fetched, imp, trainingv1alpha1.ReasonInvalidRepo, err)
+	}
+	ownerRepo := repo.OwnerRepo()
+	provider := githubApi.ProviderFor(imp.Spec.Provider, repo.HostOrDefault(), r.Issues.Hosts)
+	gc, host, err := r.Issues.clientFor(repo, provider)
+	if err != nil {
+		logger.Error(err, "Unknown host")
+		return r.importFailed(ctx, fetched, imp, trainingv1alpha1.ReasonUnknownHost, err)
+	}
+	// checked by the webhook as well, which may be disabled
+	if err := imp.Spec.ValidateTargetNamespace(imp.Namespace); err != nil {
+		logger.Error(err, "Forbidden target namespace")
+		return r.importFailed(ctx, fetched, imp, trainingv1alpha1.ReasonForbiddenNamespace, err)
+	}
+	namespace := imp.Namespace
+	nameTemplate, err := parseNameTemplate(imp.Spec.NameTemplate)
+	if err != nil {
+		logger.Error(err, "Invalid name template")
+		return r.importFailed(ctx, fetched, imp, trainingv1alpha1.ReasonInvalidNameTemplate, err)
+	}
+	token, _, err := r.Issues.resolveToken(ctx, source, host, provider, ownerRepo)
+	if err != nil {
+		err = githubApi.RedactError(err)
+		logger.Error(err, "Can't resolve Github credentials")
+		_, _ = r.importFailed(ctx, fetched, imp, trainingv1alpha1.ReasonCredentialsUnavailable, err)
+		return ctrl.Result{}, err
+	}
+
+	issues, err := githubApi.ListAllIssues(gc, ownerRepo, importQuery(imp.Spec), token)
+	if err != nil {
+		err = githubApi.RedactError(err, token)
+		logger.Error(err, "Listing issues")
+		result, _ := r.importFailed(ctx, fetched, imp, githubApi.FailureReason(err), err)
+		var limited *githubApi.RateLimitError
+		switch {
+		case errors.As(err, &limited):
+			return ctrl.Result{RequeueAfter: limited.RetryAfter(time.Now())}, nil
+		case githubApi.IsTerminal(err):
+			return result, nil
+		}
+		return result, err
+	}
+	owned, err := r.ownedIssues(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	imported, skipped := 0, 0
+	for _, issue := range issues {
+		if owned[ownedIssueKey(repo, issue.Number)] {
+			skipped++
+			continue
+		}
+		name, err := issueName(nameTemplate, repo, issue)
+		if err != nil {
+			logger.Error(err, "Invalid name template", "number", issue.Number)
+			return r.importFailed(ctx, fetched, imp, trainingv1alpha1.ReasonInvalidNameTemplate, err)
+		}
+		githubi := importedIssue(imp, namespace, name, issue)
+		if err := r.Create(ctx, &githubi); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				logger.Info("Skipping an issue whose name is taken", "number", issue.Number, "name", name)
+				skipped++
+				continue
+			}
+			return ctrl.Result{}, err
+		}
+		// spec.issueNumber already makes a reconcile which runs before this update adopt the issue,
+		// so the status is left to that reconcile once it has set the number
+		if err := r.setIssueNumber(ctx, githubi, issue); err != nil {
+			return ctrl.Result{}, err
+		}
+		imported++
+		r.Recorder.Eventf(&imp, corev1.EventTypeNormal, trainingv1alpha1.ReasonImported, "Imported issue #%d of %s as GithubIssue %s/%s",
+			issue.Number, ownerRepo, namespace, name)
+	}
+	logger.Info("Successful import", "imported", imported, "skipped", skipped)
+
+	now := metav1.Now()
+	imp.Status.Imported, imp.Status.Skipped, imp.Status.LastImportTime = imported, skipped, &now
+	imp.Status.ObservedGeneration = imp.Generation
+	setImportCondition(&imp, metav1.ConditionTrue, trainingv1alpha1.ReasonSucceeded,
+		fmt.Sprintf("Imported %d issues of %s, skipped %d which are managed already", imported, ownerRepo, skipped))
+	if err := r.Status().Update(ctx, &imp); err != nil {
+		logger.Error(err, "Can't update the import's status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// importFailed reports the failure of imp in its Ready condition and a Warning Event, it isn't retried until the spec changes
+func (r *GithubIssueImportReconciler) importFailed(ctx context.Context, fetched trainingv1alpha1.GithubIssueImport, imp trainingv1alpha1.GithubIssueImport, reason string, err error) (ctrl.Result, error) {
+	setImportCondition(&imp, metav1.ConditionFalse, reason, err.Error())
+	r.Recorder.Event(&imp, corev1.EventTypeWarning, reason, githubApi.Redact(err.Error()))
+	if !equality.Semantic.DeepEqual(fetched.Status, imp.Status) {
+		if err := r.Status().Update(ctx, &imp); err != nil {
+			r.Log.Error(err, "Can't record the failure in the import's status", "githubissueimport", imp.Name)
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// setIssueNumber sets the number and key of issue in the status of githubi, which was just created for it.
+// It is retried on a conflict with a reconcile of githubi, which may have set them already
+func (r *GithubIssueImportReconciler) setIssueNumber(ctx context.Context, githubi trainingv1alpha1.GithubIssue, issue githubApi.GithubRecieve) error {
+	key := client.ObjectKeyFromObject(&githubi)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.Get(ctx, key, &githubi); err != nil {
+			return err
+		}
+		if githubi.Status.Number > 0 {
+			return nil
+		}
+		githubi.Status.Number = issue.Number
+		githubi.Status.Key = issue.Key
+		return r.Status().Update(ctx, &githubi)
+	})
+}
+
+// ownedIssues returns the repo and number keys (see ownedIssueKey) of the issues the GithubIssues of every namespace manage
+func (r *GithubIssueImportReconciler) ownedIssues(ctx context.Context) (map[string]bool, error) {
+	githubis := trainingv1alpha1.GithubIssueList{}
+	if err := r.List(ctx, &githubis); err != nil {
+		return nil, err
+	}
+	owned := map[string]bool{}
+	for _, githubi := range githubis.Items {
+		number := githubi.Status.Number
+		if number == 0 {
+			number = githubi.Spec.IssueNumber
+		}
+		if repo, err := githubi.Spec.ResolveRepository(); err == nil && number > 0 {
+			owned[ownedIssueKey(repo, number)] = true
+		}
+	}
+	return owned, nil
+}
+
+// setImportCondition sets the Ready condition of imp, anything formatted like a Github token is redacted from message
+func setImportCondition(imp *trainingv1alpha1.GithubIssueImport, status metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(&imp.Status.Conditions, metav1.Condition{
+		Type:               trainingv1alpha1.ConditionReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            githubApi.Redact(message),
+		ObservedGeneration: imp.Generation,
+	})
+}
+
+// importQuery returns the list query of spec's filters, in Github's terms
+func importQuery(spec trainingv1alpha1.GithubIssueImportSpec) url.Values {
+	state := spec.State
+	if state == "" {
+		state = trainingv1alpha1.StateOpen
+	}
+	query := url.Values{"state": {state}}
+	if len(spec.Labels) > 0 {
+		query.Set("labels", strings.Join(spec.Labels, ","))
+	}
+	if spec.Since != nil {
+		query.Set("since", spec.Since.UTC().Format(time.RFC3339))
+	}
+	return query
+}
+
+// parseNameTemplate parses spec.nameTemplate, defaultNameTemplate when it is empty
+func parseNameTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultNameTemplate
+	}
+	parsed, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("spec.nameTemplate: %w", err)
+	}
+	return parsed, nil
+}
+
+// issueName returns the name of the GithubIssue of issue - nameTemplate's output lowercased,
+// with every run of characters a name can't have replaced by a dash
+func issueName(nameTemplate *template.Template, repo trainingv1alpha1.RepositoryRef, issue githubApi.GithubRecieve) (string, error) {
+	var out strings.Builder
+	fields := importedName{Owner: repo.Owner, Repo: repo.Name, Number: issue.Number, Key: issue.Key, Title: issue.Title}
+	if err := nameTemplate.Execute(&out, fields); err != nil {
+		return "", fmt.Errorf("spec.nameTemplate: %w", err)
+	}
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(out.String()), "-"), "-.")
+	if len(name) > validation.DNS1123SubdomainMaxLength {
+		name = strings.TrimRight(name[:validation.DNS1123SubdomainMaxLength], "-.")
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", fmt.Errorf("spec.nameTemplate makes the name %q for issue %d: %s", name, issue.Number, strings.Join(errs, ", "))
+	}
+	return name, nil
+}
+
+// importedIssue returns the GithubIssue name of issue in namespace, whose spec is the issue as it is.
+// It adopts the issue by spec.issueNumber, and is annotated with the import which created it - so no marker is written
+// into the issue's body (see githubApi.Marker)
+func importedIssue(imp trainingv1alpha1.GithubIssueImport, namespace string, name string, issue githubApi.GithubRecieve) trainingv1alpha1.GithubIssue {
+	githubi := trainingv1alpha1.GithubIssue{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: map[string]string{trainingv1alpha1.ImportedByAnnotation: imp.Namespace + "/" + imp.Name},
+		},
+		Spec: trainingv1alpha1.GithubIssueSpec{
+			Repo:           imp.Spec.Repo,
+			Provider:       imp.Spec.Provider,
+			Title:          issue.Title,
+			Description:    issue.Description,
+			IssueNumber:    issue.Number,
+			DeletionPolicy: imp.Spec.DeletionPolicy,
+		},
+	}
+	if labels := issue.LabelNames(); len(labels) > 0 {
+		githubi.Spec.Labels = labels
+	}
+	if assignees := issue.AssigneeLogins(); len(assignees) > 0 {
+		githubi.Spec.Assignees = assignees
+	}
+	if milestone := issue.MilestoneNumber(); milestone > 0 {
+		githubi.Spec.Milestone = &milestone
+	}
+	if ref := imp.Spec.CredentialsSecretRef; ref != nil {
+		githubi.Spec.CredentialsSecretRef = ref.DeepCopy()
+	}
+	return githubi
+}
+
+// SetupWithManager sets up the controller with the Manager, a GithubIssueImport is reconciled once its spec changes
+func (r *GithubIssueImportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&trainingv1alpha1.GithubIssueImport{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+	githubApi "github.com/razo7/githubissues-operator/github"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+var _ = Describe("GithubIssueImport controller", func() {
+	const (
+		RepoName        = "acme/backlog"
+		ImportName      = "backlog-import"
+		Namespace       = "default"
+		TargetNamespace = "backlog" // a namespace the import may not create GithubIssues in
+		SecretName      = "import-credentials"
+	)
+	var (
+		github     *fakeGithub
+		fakeClient client.Client
+		issues     *GithubIssueReconciler
+		r          *GithubIssueImportReconciler
+	)
+
+	BeforeEach(func() {
+		github = newFakeGithub("import-credential", RepoName)
+		github.addIssue(RepoName, "Fix the login page", "It 500s")
+		github.addIssue(RepoName, "Managed already", "by the GithubIssue below")
+		github.addIssue(RepoName, "Rotate the certificates", "before July")
+		pull := github.addIssue(RepoName, "Bump the Go version", "a pull request")
+		github.issues[RepoName][pull].PullRequest = &githubApi.GithubPullRequest{URL: "https://api.github.com/repos/acme/backlog/pulls/4"}
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: SecretName, Namespace: Namespace},
+			Data:       map[string][]byte{githubApi.DefaultSecretKey: []byte("import-credential")},
+		}
+		managed := &trainingv1alpha1.GithubIssue{
+			ObjectMeta: metav1.ObjectMeta{Name: "managed", Namespace: TargetNamespace}, // issues managed in any namespace are skipped
+			Spec:       trainingv1alpha1.GithubIssueSpec{Repo: "https://github.com/" + RepoName, Title: "Managed already"},
+			Status:     trainingv1alpha1.GithubIssueStatus{Number: 2},
+		}
+		imp := &trainingv1alpha1.GithubIssueImport{
+			ObjectMeta: metav1.ObjectMeta{Name: ImportName, Namespace: Namespace, Generation: 1},
+			Spec: trainingv1alpha1.GithubIssueImportSpec{
+				Repo:                 "https://github.com/" + RepoName,
+				DeletionPolicy:       trainingv1alpha1.DeletionPolicyOrphan,
+				CredentialsSecretRef: &trainingv1alpha1.SecretKeyReference{Name: SecretName},
+			},
+		}
+		fakeClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret, managed, imp).Build()
+		issues = &GithubIssueReconciler{
+			Client:       fakeClient,
+			Log:          zap.New(zap.WriteTo(GinkgoWriter)),
+			Scheme:       scheme.Scheme,
+			Recorder:     record.NewFakeRecorder(100),
+			GithubClient: githubApi.NewClient(github.URL, github.Client()),
+		}
+		r = &GithubIssueImportReconciler{
+			Client:   fakeClient,
+			Log:      zap.New(zap.WriteTo(GinkgoWriter)),
+			Scheme:   scheme.Scheme,
+			Recorder: record.NewFakeRecorder(100),
+			Issues:   issues,
+		}
+	})
+
+	AfterEach(func() {
+		github.Close()
+	})
+
+	runImport := func() trainingv1alpha1.GithubIssueImport {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: ImportName, Namespace: Namespace}})
+		Expect(err).NotTo(HaveOccurred())
+		imp := trainingv1alpha1.GithubIssueImport{}
+		Expect(fakeClient.Get(context.Background(), types.NamespacedName{Name: ImportName, Namespace: Namespace}, &imp)).To(Succeed())
+		return imp
+	}
+
+	updateSpec := func(update func(spec *trainingv1alpha1.GithubIssueImportSpec)) {
+		imp := trainingv1alpha1.GithubIssueImport{}
+		Expect(fakeClient.Get(context.Background(), types.NamespacedName{Name: ImportName, Namespace: Namespace}, &imp)).To(Succeed())
+		update(&imp.Spec)
+		imp.Generation++ // the fake client doesn't bump it
+		Expect(fakeClient.Update(context.Background(), &imp)).To(Succeed())
+	}
+
+	It("should create a GithubIssue with its number for every issue nothing manages yet", func() {
+		imp := runImport()
+		Expect(imp.Status.Imported).To(Equal(2))
+		Expect(imp.Status.Skipped).To(Equal(1))
+		Expect(imp.Status.ObservedGeneration).To(Equal(int64(1)))
+		ready := meta.FindStatusCondition(imp.Status.Conditions, trainingv1alpha1.ConditionReady)
+		Expect(ready.Status).To(Equal(metav1.ConditionTrue))
+
+		githubis := trainingv1alpha1.GithubIssueList{}
+		Expect(fakeClient.List(context.Background(), &githubis, client.InNamespace(Namespace))).To(Succeed())
+		Expect(githubis.Items).To(HaveLen(2))
+		githubi := trainingv1alpha1.GithubIssue{}
+		Expect(fakeClient.Get(context.Background(), types.NamespacedName{Name: "backlog-3", Namespace: Namespace}, &githubi)).To(Succeed())
+		Expect(githubi.Status.Number).To(Equal(3))
+		Expect(githubi.Spec.IssueNumber).To(Equal(3))
+		Expect(githubi.Spec.Title).To(Equal("Rotate the certificates"))
+		Expect(githubi.Spec.Description).To(Equal("before July"))
+		Expect(githubi.Spec.DeletionPolicy).To(Equal(trainingv1alpha1.DeletionPolicyOrphan))
+		Expect(githubi.Spec.CredentialsSecretRef.Name).To(Equal(SecretName))
+		Expect(githubi.Annotations[trainingv1alpha1.ImportedByAnnotation]).To(Equal(Namespace + "/" + ImportName))
+
+		By("adopting the imported issue instead of creating another one")
+		_, err := issues.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "backlog-3", Namespace: Namespace}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakeClient.Get(context.Background(), types.NamespacedName{Name: "backlog-3", Namespace: Namespace}, &githubi)).To(Succeed())
+		Expect(githubi.Status.Number).To(Equal(3))
+		Expect(githubi.Finalizers).To(ContainElement(githubApi.FinalizerName))
+		Expect(github.issue(RepoName, 5)).To(BeNil())
+		Expect(github.issue(RepoName, 3).Description).To(Equal("before July")) // no marker is written into the body
+
+		By("leaving the issue open once the imported GithubIssue is deleted")
+		Expect(fakeClient.Delete(context.Background(), &githubi)).To(Succeed())
+		_, err = issues.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "backlog-3", Namespace: Namespace}})
+		Expect(err).NotTo(HaveOccurred())
+		err = fakeClient.Get(context.Background(), types.NamespacedName{Name: "backlog-3", Namespace: Namespace}, &githubi)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(github.issue(RepoName, 3).State).To(Equal(trainingv1alpha1.StateOpen))
+	})
+
+	It("should leave the number to a reconcile which set it first", func() {
+		runImport()
+		stale := trainingv1alpha1.GithubIssue{}
+		Expect(fakeClient.Get(context.Background(), types.NamespacedName{Name: "backlog-1", Namespace: Namespace}, &stale)).To(Succeed())
+		githubi := *stale.DeepCopy()
+		githubi.Status.Number, githubi.Status.Title = 1, "reconciled"
+		Expect(fakeClient.Status().Update(context.Background(), &githubi)).To(Succeed())
+
+		stale.Status.Number = 0
+		Expect(r.setIssueNumber(context.Background(), stale, githubApi.GithubRecieve{Number: 1})).To(Succeed())
+		Expect(fakeClient.Get(context.Background(), types.NamespacedName{Name: "backlog-1", Namespace: Namespace}, &githubi)).To(Succeed())
+		Expect(githubi.Status.Title).To(Equal("reconciled"))
+	})
+
+	It("should import again only once the spec changes, skipping the imported issues", func() {
+		runImport()
+		imp := runImport()
+		Expect(imp.Status.Imported).To(Equal(2)) // the same generation isn't imported again
+
+		github.addIssue(RepoName, "Filed after the import", "")
+		updateSpec(func(spec *trainingv1alpha1.GithubIssueImportSpec) { spec.Labels = []string{"bug"} })
+		imp = runImport()
+		Expect(imp.Status.Imported).To(Equal(1))
+		Expect(imp.Status.Skipped).To(Equal(3))
+		Expect(imp.Status.ObservedGeneration).To(Equal(int64(2)))
+	})
+
+	It("should refuse to import into another namespace", func() {
+		updateSpec(func(spec *trainingv1alpha1.GithubIssueImportSpec) { spec.TargetNamespace = TargetNamespace })
+		imp := runImport()
+		ready := meta.FindStatusCondition(imp.Status.Conditions, trainingv1alpha1.ConditionReady)
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).To(Equal(trainingv1alpha1.ReasonForbiddenNamespace))
+		githubis := trainingv1alpha1.GithubIssueList{}
+		Expect(fakeClient.List(context.Background(), &githubis)).To(Succeed())
+		Expect(githubis.Items).To(HaveLen(1)) // only the managed one
+	})
+
+	It("should name the GithubIssues by spec.nameTemplate", func() {
+		updateSpec(func(spec *trainingv1alpha1.GithubIssueImportSpec) { spec.NameTemplate = "{{.Owner}}/{{.Title}}" })
+		runImport()
+		githubi := trainingv1alpha1.GithubIssue{}
+		Expect(fakeClient.Get(context.Background(), types.NamespacedName{Name: "acme-fix-the-login-page", Namespace: Namespace}, &githubi)).To(Succeed())
+		Expect(githubi.Status.Number).To(Equal(1))
+	})
+
+	It("should report a name template which can't make a name", func() {
+		updateSpec(func(spec *trainingv1alpha1.GithubIssueImportSpec) { spec.NameTemplate = "{{.Labels}}" })
+		imp := runImport()
+		ready := meta.FindStatusCondition(imp.Status.Conditions, trainingv1alpha1.ConditionReady)
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).To(Equal(trainingv1alpha1.ReasonInvalidNameTemplate))
+		githubis := trainingv1alpha1.GithubIssueList{}
+		Expect(fakeClient.List(context.Background(), &githubis, client.InNamespace(Namespace))).To(Succeed())
+		Expect(githubis.Items).To(BeEmpty())
+	})
+})
@@ -0,0 +1,89 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+	githubApi "github.com/razo7/githubissues-operator/github"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	managedIssuesDesc = prometheus.NewDesc("githubissues_managed_issues",
+		"GithubIssues by the state of their issue and their repo", []string{"state", "repo"}, nil)
+	blockedDeletionsDesc = prometheus.NewDesc("githubissues_finalizer_blocked_deletions",
+		"GithubIssues being deleted whose finalizer is still waiting for their issue to be closed, by repo", []string{"repo"}, nil)
+)
+
+// issueCollector reports the GithubIssues of the manager's cache when the metrics are scraped,
+// so the gauges never drift from the GithubIssues which exist
+type issueCollector struct {
+	client client.Reader
+	log    logr.Logger
+}
+
+// Describe implements prometheus.Collector
+func (c *issueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- managedIssuesDesc
+	ch <- blockedDeletionsDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *issueCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	githubis := trainingv1alpha1.GithubIssueList{}
+	if err := c.client.List(ctx, &githubis); err != nil {
+		c.log.Error(err, "Can't list GithubIssues for metrics")
+		return
+	}
+	type stateRepo struct{ state, repo string }
+	managed := map[stateRepo]int{}
+	blocked := map[string]int{}
+	for _, githubi := range githubis.Items {
+		repo := "invalid"
+		if ref, err := githubi.Spec.ResolveRepository(); err == nil {
+			repo = ref.Key()
+		}
+		managed[stateRepo{issueState(githubi), repo}]++
+		if !githubi.DeletionTimestamp.IsZero() && githubApi.ContainsString(githubi.Finalizers, githubApi.FinalizerName) {
+			blocked[repo]++
+		}
+	}
+	for key, count := range managed {
+		ch <- prometheus.MustNewConstMetric(managedIssuesDesc, prometheus.GaugeValue, float64(count), key.state, key.repo)
+	}
+	for repo, count := range blocked {
+		ch <- prometheus.MustNewConstMetric(blockedDeletionsDesc, prometheus.GaugeValue, float64(count), repo)
+	}
+}
+
+// issueState returns the state label of githubi - open or closed, pending before its issue exists and failed for a failed repo
+func issueState(githubi trainingv1alpha1.GithubIssue) string {
+	switch githubi.Status.State {
+	case "", " ":
+		return "pending"
+	case githubApi.Fail_Repo:
+		return "failed"
+	}
+	return githubi.Status.State
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+	githubApi "github.com/razo7/githubissues-operator/github"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("GithubIssue metrics", func() {
+	It("should report the managed issues by state and repo and the deletions blocked by the finalizer", func() {
+		githubIssue := func(name string, repo string, state string) *trainingv1alpha1.GithubIssue {
+			githubi := &trainingv1alpha1.GithubIssue{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec:       trainingv1alpha1.GithubIssueSpec{Repo: repo, Title: name},
+			}
+			githubi.Status.State = state
+			return githubi
+		}
+		deleting := githubIssue("deleting", "https://github.com/razo7/other", "open")
+		deleting.Finalizers = []string{githubApi.FinalizerName}
+		deleting.DeletionTimestamp = &metav1.Time{Time: metav1.Now().Time}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			githubIssue("first", "https://github.com/razo7/githubissues-operator", "open"),
+			githubIssue("second", "https://github.com/Razo7/GithubIssues-Operator.git", "open"),
+			githubIssue("third", "https://github.com/razo7/githubissues-operator", "closed"),
+			githubIssue("new", "https://github.com/razo7/githubissues-operator", ""),
+			githubIssue("broken", "https://github.com/razo7/githubissues-operator/issues", ""),
+			deleting,
+		).Build()
+
+		collector := &issueCollector{client: fakeClient, log: ctrl.Log.WithName("metrics-suite")}
+		Expect(testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP githubissues_managed_issues GithubIssues by the state of their issue and their repo
+# TYPE githubissues_managed_issues gauge
+githubissues_managed_issues{repo="github.com/razo7/githubissues-operator",state="open"} 2
+githubissues_managed_issues{repo="github.com/razo7/githubissues-operator",state="closed"} 1
+githubissues_managed_issues{repo="github.com/razo7/githubissues-operator",state="pending"} 1
+githubissues_managed_issues{repo="github.com/razo7/other",state="open"} 1
+githubissues_managed_issues{repo="invalid",state="pending"} 1
+# HELP githubissues_finalizer_blocked_deletions GithubIssues being deleted whose finalizer is still waiting for their issue to be closed, by repo
+# TYPE githubissues_finalizer_blocked_deletions gauge
+githubissues_finalizer_blocked_deletions{repo="github.com/razo7/other"} 1
+`))).To(Succeed())
+	})
+})
@@ -0,0 +1,132 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+	githubApi "github.com/razo7/githubissues-operator/github"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+var _ = Describe("GitLab repos", func() {
+	const (
+		SecretName = "gitlab-credentials"
+		IssueName  = "gitlab-githubissue"
+		Namespace  = "default"
+	)
+	var (
+		server  *httptest.Server
+		created map[string]interface{}
+		tokens  []string
+	)
+
+	BeforeEach(func() {
+		created, tokens = nil, nil
+		// a stand-in for a GitLab server which creates and returns issue 1 of team/project
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			tokens = append(tokens, req.Header.Get("PRIVATE-TOKEN"))
+			switch {
+			case req.URL.EscapedPath() == githubApi.GitlabAPIPath+"/projects/team%2Fproject/issues" && req.Method == "POST":
+				Expect(json.NewDecoder(req.Body).Decode(&created)).To(Succeed())
+				created["iid"], created["state"] = 1, "opened"
+				w.WriteHeader(http.StatusCreated)
+				_ = json.NewEncoder(w).Encode(created)
+			case req.URL.EscapedPath() == githubApi.GitlabAPIPath+"/projects/team%2Fproject/issues":
+				_, _ = w.Write([]byte("[]"))
+			case req.URL.EscapedPath() == githubApi.GitlabAPIPath+"/projects/team%2Fproject/issues/1" && req.Method == "GET":
+				_ = json.NewEncoder(w).Encode(created)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	reconcile := func(repo string, hosts *githubApi.Hosts) trainingv1alpha1.GithubIssue {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: SecretName, Namespace: Namespace},
+			Data:       map[string][]byte{githubApi.DefaultSecretKey: []byte("glpat-credential")},
+		}
+		githubi := &trainingv1alpha1.GithubIssue{
+			ObjectMeta: metav1.ObjectMeta{Name: IssueName, Namespace: Namespace},
+			Spec: trainingv1alpha1.GithubIssueSpec{
+				Repo:                 repo,
+				Title:                "GitLab issue",
+				Description:          "Hi from testing K8s",
+				CredentialsSecretRef: &trainingv1alpha1.SecretKeyReference{Name: SecretName},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret, githubi).Build()
+		r := &GithubIssueReconciler{
+			Client:       fakeClient,
+			Log:          zap.New(zap.WriteTo(GinkgoWriter)),
+			Scheme:       scheme.Scheme,
+			GithubClient: githubApi.NewClient(server.URL, server.Client()), // never called for a GitLab repo
+			Recorder:     record.NewFakeRecorder(100),
+			Hosts:        hosts,
+		}
+		for i := 0; i < 2; i++ { // the first reconcile registers the finalizer, the second one creates the issue
+			_, _ = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: IssueName, Namespace: Namespace}})
+		}
+		stored := trainingv1alpha1.GithubIssue{}
+		Expect(fakeClient.Get(context.Background(), types.NamespacedName{Name: IssueName, Namespace: Namespace}, &stored)).To(Succeed())
+		return stored
+	}
+
+	It("should create the issue of a repo on a GitLab host through the same reconciler", func() {
+		hosts, err := githubApi.NewHosts(githubApi.HostsConfig{Hosts: []githubApi.HostConfig{
+			{Host: "code.example.com", Provider: trainingv1alpha1.ProviderGitlab, APIURL: server.URL + githubApi.GitlabAPIPath},
+		}}, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		githubi := reconcile("https://code.example.com/team/project", hosts)
+		Expect(githubi.Status.Number).To(Equal(1))
+		Expect(githubi.Status.State).To(Equal(trainingv1alpha1.StateOpen))
+		Expect(created["title"]).To(Equal("GitLab issue"))
+		Expect(tokens).NotTo(BeEmpty())
+		for _, token := range tokens {
+			Expect(token).To(Equal("glpat-credential"))
+		}
+	})
+
+	It("should refuse a GitLab host which isn't configured", func() {
+		githubi := reconcile("https://gitlab.example.com/team/project", nil)
+		Expect(githubi.Status.Number).To(BeZero())
+		Expect(tokens).To(BeEmpty())
+		synced := meta.FindStatusCondition(githubi.Status.Conditions, trainingv1alpha1.ConditionSynced)
+		Expect(synced).NotTo(BeNil())
+		Expect(synced.Reason).To(Equal(trainingv1alpha1.ReasonUnknownHost))
+	})
+})
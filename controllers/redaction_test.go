@@ -0,0 +1,128 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+	githubApi "github.com/razo7/githubissues-operator/github"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// roundTripperFunc lets a function stand in for the transport of an http.Client
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var _ = Describe("Credential redaction", func() {
+	const (
+		// Token isn't formatted like a Github token, so only redacting the configured value keeps it out
+		Token      = "operator-s3cr3t-credential-value"
+		SecretName = "redaction-credentials"
+		IssueName  = "redaction-githubissue"
+		Namespace  = "default"
+	)
+
+	// reconcileOutput reconciles a new GithubIssue against gc and returns everything the reconciler wrote -
+	// its logs, its Events, the GithubIssue it stored and the error it returned
+	reconcileOutput := func(gc githubApi.Client) string {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: SecretName, Namespace: Namespace},
+			Data:       map[string][]byte{githubApi.DefaultSecretKey: []byte(Token)},
+		}
+		githubi := &trainingv1alpha1.GithubIssue{
+			ObjectMeta: metav1.ObjectMeta{Name: IssueName, Namespace: Namespace},
+			Spec: trainingv1alpha1.GithubIssueSpec{
+				Repo:                 "https://github.com/razo7/githubissues-operator",
+				Title:                "Redaction",
+				Description:          "the token must not leak",
+				CredentialsSecretRef: &trainingv1alpha1.SecretKeyReference{Name: SecretName},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret, githubi).Build()
+		logs := &bytes.Buffer{}
+		recorder := record.NewFakeRecorder(100)
+		r := &GithubIssueReconciler{
+			Client:       fakeClient,
+			Log:          zap.New(zap.WriteTo(logs)),
+			Scheme:       scheme.Scheme,
+			GithubClient: gc,
+			Recorder:     recorder,
+		}
+		output := &bytes.Buffer{}
+		for i := 0; i < 2; i++ { // the first reconcile registers the finalizer, the second one retries the failed call
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: IssueName, Namespace: Namespace}})
+			if err != nil {
+				fmt.Fprintln(output, err.Error())
+			}
+		}
+		stored := trainingv1alpha1.GithubIssue{}
+		Expect(fakeClient.Get(context.Background(), types.NamespacedName{Name: IssueName, Namespace: Namespace}, &stored)).To(Succeed())
+		status, err := json.Marshal(stored)
+		Expect(err).NotTo(HaveOccurred())
+		output.Write(status)
+		output.Write(logs.Bytes())
+		close(recorder.Events)
+		for e := range recorder.Events {
+			fmt.Fprintln(output, e)
+		}
+		return output.String()
+	}
+
+	for _, code := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound} {
+		code := code
+		It(fmt.Sprintf("should keep the token out of the reconciler's output on a %d", code), func() {
+			// a stand-in which echoes the credential back, as a misbehaving proxy might
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("X-Echo", req.Header.Get("Authorization"))
+				w.WriteHeader(code)
+				fmt.Fprintf(w, `{"message": "Bad credentials %s"}`, req.Header.Get("Authorization"))
+			}))
+			defer server.Close()
+			output := reconcileOutput(githubApi.NewClient(server.URL, server.Client()))
+			Expect(output).To(ContainSubstring(fmt.Sprint(code)))
+			Expect(output).NotTo(ContainSubstring(Token))
+		})
+	}
+
+	It("should keep the token out of the reconciler's output on a network error", func() {
+		httpClient := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, fmt.Errorf("connection reset while sending %q", req.Header.Get("Authorization"))
+		})}
+		output := reconcileOutput(githubApi.NewClient("https://github.invalid", httpClient))
+		Expect(output).To(ContainSubstring("connection reset"))
+		Expect(output).To(ContainSubstring(githubApi.Redacted))
+		Expect(output).NotTo(ContainSubstring(Token))
+	})
+})
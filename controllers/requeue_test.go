@@ -0,0 +1,80 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+	githubApi "github.com/razo7/githubissues-operator/github"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Failed reconciles", func() {
+	var r *GithubIssueReconciler
+
+	BeforeEach(func() {
+		r = &GithubIssueReconciler{Recorder: record.NewFakeRecorder(100), ResyncPeriod: 10 * time.Minute}
+	})
+
+	It("should retry a terminal failure on the next resync instead of dropping the GithubIssue", func() {
+		for _, err := range []error{
+			&githubApi.AuthError{OwnerRepo: "razo7/githubissues-operator", Code: 401},
+			&githubApi.NotFoundError{OwnerRepo: "razo7/githubissues-operator", Code: 404},
+			&githubApi.ValidationError{OwnerRepo: "razo7/githubissues-operator", Code: 422},
+		} {
+			result, returned := r.requeueFailure(&trainingv1alpha1.GithubIssue{}, err)
+			Expect(returned).NotTo(HaveOccurred())
+			Expect(result).To(Equal(ctrl.Result{RequeueAfter: 10 * time.Minute}))
+		}
+	})
+
+	It("should retry any other failure with backoff", func() {
+		err := &githubApi.RequestError{OwnerRepo: "razo7/githubissues-operator", Err: errors.New("connection reset")}
+		result, returned := r.requeueFailure(&trainingv1alpha1.GithubIssue{}, err)
+		Expect(returned).To(Equal(err))
+		Expect(result).To(Equal(ctrl.Result{}))
+	})
+
+	It("should record the failed state with the conditions of a failed reconcile", func() {
+		fetched := &trainingv1alpha1.GithubIssue{
+			ObjectMeta: metav1.ObjectMeta{Name: "failing", Namespace: "default"},
+			Spec:       trainingv1alpha1.GithubIssueSpec{Repo: "https://github.com/razo7/githubissues-operator", Title: "failing"},
+		}
+		fetched.Status.State = "open"
+		fetched.Status.Number = 3
+		r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(fetched).Build()
+		githubi := fetched.DeepCopy()
+		githubApi.SetCondition(githubi, trainingv1alpha1.ConditionSynced, metav1.ConditionFalse, trainingv1alpha1.ReasonNotFound, "Github responded 404")
+
+		r.recordFailure(context.Background(), *fetched, *githubi)
+		stored := &trainingv1alpha1.GithubIssue{}
+		Expect(r.Get(context.Background(), client.ObjectKeyFromObject(fetched), stored)).To(Succeed())
+		Expect(stored.Status.State).To(Equal(githubApi.Fail_Repo))
+		Expect(stored.Status.Number).To(Equal(3))
+		Expect(issueState(*stored)).To(Equal("failed"))
+	})
+})
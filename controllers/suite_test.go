@@ -17,6 +17,7 @@ limitations under the License.
 package controllers
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -33,6 +34,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+	githubApi "github.com/razo7/githubissues-operator/github"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -42,6 +44,7 @@ import (
 var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
+var fakeGithubServer *fakeGithub
 
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
@@ -69,9 +72,9 @@ var _ = BeforeSuite(func() {
 
 	//+kubebuilder:scaffold:scheme
 
-	k8sManager, err := ctrl.NewManager(cfg, ctrl.Options{
+	k8sManager, err := ctrl.NewManager(cfg, SecretCacheOptions(ctrl.Options{
 		Scheme: scheme.Scheme,
-	})
+	}))
 	Expect(err).ToNot(HaveOccurred())
 
 	// k8sClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
@@ -79,11 +82,25 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(k8sClient).NotTo(BeNil())
 
-	err = (&GithubIssueReconciler{
+	fakeGithubServer = newFakeGithub(os.Getenv("GIT_TOKEN_GI"), "razo7/githubissues-operator")
+
+	issueReconciler := &GithubIssueReconciler{
 		Client: k8sClient,
 		// Client: k8sManager.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("GithubIssue-suite"),
-		Scheme: k8sManager.GetScheme(),
+		Log:          ctrl.Log.WithName("controllers").WithName("GithubIssue-suite"),
+		Scheme:       k8sManager.GetScheme(),
+		Recorder:     k8sManager.GetEventRecorderFor("githubissue-controller"),
+		GithubClient: githubApi.NewClient(fakeGithubServer.URL, fakeGithubServer.Client()),
+	}
+	err = issueReconciler.SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&GithubIssueImportReconciler{
+		Client:   k8sClient,
+		Log:      ctrl.Log.WithName("controllers").WithName("GithubIssueImport-suite"),
+		Scheme:   k8sManager.GetScheme(),
+		Recorder: k8sManager.GetEventRecorderFor("githubissueimport-controller"),
+		Issues:   issueReconciler,
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
@@ -96,6 +113,7 @@ var _ = BeforeSuite(func() {
 
 var _ = AfterSuite(func() {
 	By("tearing down the test environment")
+	fakeGithubServer.Close()
 	err := testEnv.Stop()
 	Expect(err).NotTo(HaveOccurred())
 })
@@ -0,0 +1,129 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+	githubApi "github.com/razo7/githubissues-operator/github"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// WebhookPath is where the WebhookReceiver accepts Github webhook deliveries
+const WebhookPath = "/webhook"
+
+// maxWebhookPayload bounds the size of a delivery, Github caps them at 25MB but issue events are far smaller
+const maxWebhookPayload = 5 << 20
+
+// WebhookReceiver accepts Github issues and issue_comment webhook deliveries and enqueues the GithubIssue managing the issue,
+// so changes made on Github are reconciled right away instead of on the next resync
+type WebhookReceiver struct {
+	// Client lists GithubIssues by ownedIssueIndex, it must be the manager's client the index is registered with
+	Client client.Reader
+	Log    logr.Logger
+	// Secret is the webhook secret the deliveries are signed with
+	Secret []byte
+	// Events receives a GenericEvent for each GithubIssue to reconcile, see GithubIssueReconciler.WebhookEvents
+	Events chan<- event.GenericEvent
+	// BindAddress is the address Start serves WebhookPath on
+	BindAddress string
+	// Forget drops the issue of a delivery from the GraphQL batches of the resyncs, so its reconcile doesn't read
+	// the version fetched before the change (see GithubIssueReconciler.ForgetIssue). Nil leaves the batches as they are
+	Forget func(repo trainingv1alpha1.RepositoryRef, number int)
+}
+
+// ServeHTTP verifies the signature of a delivery and enqueues the GithubIssues managing its issue
+func (w *WebhookReceiver) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		resp.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := ioutil.ReadAll(http.MaxBytesReader(resp, req.Body, maxWebhookPayload))
+	if err != nil {
+		resp.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := githubApi.ValidateSignature(payload, req.Header.Get("X-Hub-Signature-256"), w.Secret); err != nil {
+		w.Log.Info("Rejecting webhook delivery", "delivery", req.Header.Get("X-GitHub-Delivery"), "reason", err.Error())
+		resp.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	delivery, relevant, err := githubApi.ParseWebhook(req.Header.Get("X-GitHub-Event"), payload)
+	if err != nil {
+		w.Log.Error(err, "Can't parse webhook delivery", "delivery", req.Header.Get("X-GitHub-Delivery"))
+		resp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !relevant { // e.g. ping, acknowledged without doing anything
+		resp.WriteHeader(http.StatusNoContent)
+		return
+	}
+	repo, err := trainingv1alpha1.ParseRepoURL(delivery.Repository.HTMLURL)
+	if err != nil {
+		w.Log.Error(err, "Can't parse the repo of webhook delivery", "delivery", req.Header.Get("X-GitHub-Delivery"))
+		resp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if w.Forget != nil {
+		w.Forget(repo, delivery.Issue.Number)
+	}
+	githubis := trainingv1alpha1.GithubIssueList{}
+	if err := w.Client.List(req.Context(), &githubis,
+		client.MatchingFields{ownedIssueIndex: ownedIssueKey(repo, delivery.Issue.Number)}); err != nil {
+		w.Log.Error(err, "Can't list GithubIssues for webhook delivery", "repo", delivery.Repository.FullName, "number", delivery.Issue.Number)
+		resp.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	for i := range githubis.Items {
+		select {
+		case w.Events <- event.GenericEvent{Object: &githubis.Items[i]}:
+		case <-req.Context().Done():
+			resp.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.Log.Info("Webhook delivery", "event", req.Header.Get("X-GitHub-Event"), "action", delivery.Action,
+		"repo", delivery.Repository.FullName, "number", delivery.Issue.Number, "enqueued", len(githubis.Items))
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+// Start serves WebhookPath on BindAddress until ctx is done, it lets the WebhookReceiver run as a manager Runnable.
+// It runs only on the leader, which is the only one reconciling the enqueued GithubIssues.
+func (w *WebhookReceiver) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle(WebhookPath, w)
+	server := &http.Server{Addr: w.BindAddress, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	w.Log.Info("Serving Github webhooks", "address", w.BindAddress, "path", WebhookPath)
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AppTokenSource authenticates as a Github App - https://docs.github.com/en/developers/apps/building-github-apps/authenticating-with-github-apps
+// It signs a JWT with the App's private key, exchanges it for an installation access token of the repo's installation,
+// and caches the token until shortly before it expires.
+// The calls of an installation are made under its own lock, so the reconciles of other installations don't wait for them
+type AppTokenSource struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// Now returns the current time, it is replaced in tests
+	Now func() time.Time
+
+	mu            sync.Mutex
+	installations map[string]int64            // appID/key digest/ownerRepo -> installation ID
+	tokens        map[string]*appInstallation // appID/key digest/installation ID -> its token
+}
+
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// appInstallation is the cached token of an installation, its lock is held while a token is minted
+// so the installation's reconciles wait for that token instead of minting their own
+type appInstallation struct {
+	mu    sync.Mutex
+	token installationToken
+}
+
+// NewAppTokenSource returns an AppTokenSource for baseURL. An empty baseURL means DefaultBaseURL and a nil httpClient means http.DefaultClient
+func NewAppTokenSource(baseURL string, httpClient *http.Client) *AppTokenSource {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &AppTokenSource{
+		BaseURL:       strings.TrimSuffix(baseURL, "/"),
+		HTTPClient:    httpClient,
+		Now:           time.Now,
+		installations: map[string]int64{},
+		tokens:        map[string]*appInstallation{},
+	}
+}
+
+// Token returns an installation access token of appID for the installation which has access to ownerRepo.
+// The cache is keyed by the private key as well, an App ID is public so a cached token is served only to the key which minted it
+func (a *AppTokenSource) Token(appID string, privateKeyPEM []byte, ownerRepo string) (string, error) {
+	now := a.Now()
+	app := appID + "/" + keyDigest(privateKeyPEM)
+	a.mu.Lock()
+	installationID, ok := a.installations[app+"/"+ownerRepo]
+	a.mu.Unlock()
+	var jwt string
+	if !ok {
+		var err error
+		if jwt, err = signAppJWT(appID, privateKeyPEM, now); err != nil {
+			return "", err
+		}
+		var installation struct {
+			ID int64 `json:"id"`
+		}
+		if err := a.appCall("GET", "/repos/"+ownerRepo+"/installation", jwt, Ok_Code, &installation); err != nil {
+			return "", fmt.Errorf("%v: %w", APP_ERROR, err)
+		}
+		installationID = installation.ID
+		a.mu.Lock()
+		a.installations[app+"/"+ownerRepo] = installationID
+		a.mu.Unlock()
+	}
+	installation := a.installation(app, installationID)
+	installation.mu.Lock()
+	defer installation.mu.Unlock()
+	if now.Add(AppTokenRefreshMargin).Before(installation.token.ExpiresAt) { // minted by another reconcile meanwhile
+		return installation.token.Token, nil
+	}
+	if jwt == "" {
+		var err error
+		if jwt, err = signAppJWT(appID, privateKeyPEM, now); err != nil {
+			return "", err
+		}
+	}
+	var minted installationToken
+	if err := a.appCall("POST", "/app/installations/"+strconv.FormatInt(installationID, 10)+"/access_tokens", jwt, Created_Code, &minted); err != nil {
+		a.mu.Lock()
+		delete(a.installations, app+"/"+ownerRepo) // the App may have been reinstalled, look the installation up again next time
+		a.mu.Unlock()
+		return "", fmt.Errorf("%v: %w", APP_ERROR, err)
+	}
+	installation.token = minted
+	return minted.Token, nil
+}
+
+// installation returns the cached token of installation installationID of app, an appID/key digest pair
+func (a *AppTokenSource) installation(app string, installationID int64) *appInstallation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := app + "/" + strconv.FormatInt(installationID, 10)
+	installation, ok := a.tokens[key]
+	if !ok {
+		installation = &appInstallation{}
+		a.tokens[key] = installation
+	}
+	return installation
+}
+
+// appCall makes a call authenticated as the App itself and decodes the response into out, the JWT is redacted from its error
+func (a *AppTokenSource) appCall(method string, path string, jwt string, expectedCode int, out interface{}) error {
+	return RedactError(a.sendAppCall(method, path, jwt, expectedCode, out), jwt)
+}
+
+// sendAppCall makes the call of appCall
+func (a *AppTokenSource) sendAppCall(method string, path string, jwt string, expectedCode int, out interface{}) error {
+	req, err := http.NewRequest(method, a.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	start := time.Now()
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		observeCall(a.BaseURL, method, 0, start)
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	observeCall(a.BaseURL, method, resp.StatusCode, start)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != expectedCode {
+		return fmt.Errorf("%s %s, bad HTTP response code - %d", method, path, resp.StatusCode)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// keyDigest returns the SHA-256 of a PEM private key, the whole digest so another key can't be crafted to match it
+func keyDigest(privateKeyPEM []byte) string {
+	sum := sha256.Sum256(privateKeyPEM)
+	return hex.EncodeToString(sum[:])
+}
+
+// signAppJWT returns the JWT of appID signed with its PEM private key, see appJWT
+func signAppJWT(appID string, privateKeyPEM []byte, now time.Time) (string, error) {
+	key, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return "", err
+	}
+	return appJWT(appID, key, now)
+}
+
+// appJWT returns a RS256 JWT identifying appID, valid for 9 minutes (Github allows up to 10)
+func appJWT(appID string, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(), // backdated against clock drift
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": appID,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey decodes a PEM RSA private key, Github issues PKCS#1 keys but PKCS#8 is accepted as well
+func parsePrivateKey(privateKeyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("%v: private key is not PEM encoded", APP_ERROR)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", APP_ERROR, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%v: private key is not an RSA key", APP_ERROR)
+	}
+	return key, nil
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Github App token source", func() {
+	const (
+		RepoName  = "razo7/githubissues-operator"
+		OtherRepo = "razo7/other-org-repo" // a repo of installation 43, whose tokens are minted once release is closed
+		AppID     = "1234"
+	)
+	var (
+		server  *httptest.Server
+		key     *rsa.PrivateKey
+		keyPEM  []byte
+		now     time.Time
+		minted  int
+		lookups int
+		entered chan struct{}
+		release chan struct{}
+	)
+
+	// verifyJWT checks the App JWT in the Authorization header against the test key
+	verifyJWT := func(req *http.Request) bool {
+		parts := strings.Split(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "), ".")
+		if len(parts) != 3 {
+			return false
+		}
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature) != nil {
+			return false
+		}
+		var claims struct {
+			Iss string `json:"iss"`
+		}
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		return json.Unmarshal(payload, &claims) == nil && claims.Iss == AppID
+	}
+
+	BeforeEach(func() {
+		var err error
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		now = time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+		minted, lookups = 0, 0
+		entered, release = make(chan struct{}, 1), make(chan struct{})
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if !verifyJWT(req) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			switch {
+			case req.Method == "GET" && req.URL.Path == "/repos/"+RepoName+"/installation":
+				lookups++
+				_, _ = w.Write([]byte(`{"id": 42}`))
+			case req.Method == "GET" && req.URL.Path == "/repos/"+OtherRepo+"/installation":
+				_, _ = w.Write([]byte(`{"id": 43}`))
+			case req.Method == "POST" && req.URL.Path == "/app/installations/43/access_tokens":
+				entered <- struct{}{}
+				<-release
+				w.WriteHeader(http.StatusCreated)
+				_ = json.NewEncoder(w).Encode(installationToken{Token: "ghs_other", ExpiresAt: now.Add(time.Hour)})
+			case req.Method == "POST" && req.URL.Path == "/app/installations/42/access_tokens":
+				minted++
+				w.WriteHeader(http.StatusCreated)
+				_ = json.NewEncoder(w).Encode(installationToken{Token: fmt.Sprintf("ghs_%d", minted), ExpiresAt: now.Add(time.Hour)})
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+		server.Close()
+	})
+
+	newSource := func() *AppTokenSource {
+		source := NewAppTokenSource(server.URL, server.Client())
+		source.Now = func() time.Time { return now }
+		return source
+	}
+
+	It("should exchange a signed JWT for an installation token", func() {
+		token, err := newSource().Token(AppID, keyPEM, RepoName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal("ghs_1"))
+	})
+
+	It("should cache the token until shortly before it expires", func() {
+		source := newSource()
+		_, err := source.Token(AppID, keyPEM, RepoName)
+		Expect(err).NotTo(HaveOccurred())
+		now = now.Add(30 * time.Minute)
+		token, err := source.Token(AppID, keyPEM, RepoName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal("ghs_1"))
+		Expect(minted).To(Equal(1))
+
+		now = now.Add(26 * time.Minute) // within AppTokenRefreshMargin of the expiry
+		token, err = source.Token(AppID, keyPEM, RepoName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal("ghs_2"))
+		Expect(lookups).To(Equal(1))
+	})
+
+	It("should mint the token of an installation while another installation's is being minted", func() {
+		source := newSource()
+		other := make(chan string, 1)
+		go func() {
+			defer GinkgoRecover()
+			token, err := source.Token(AppID, keyPEM, OtherRepo)
+			Expect(err).NotTo(HaveOccurred())
+			other <- token
+		}()
+		Eventually(entered).Should(Receive())
+		tokens := make(chan string, 1)
+		go func() {
+			defer GinkgoRecover()
+			token, err := source.Token(AppID, keyPEM, RepoName)
+			Expect(err).NotTo(HaveOccurred())
+			tokens <- token
+		}()
+		Eventually(tokens).Should(Receive(Equal("ghs_1")))
+		close(release)
+		Eventually(other).Should(Receive(Equal("ghs_other")))
+	})
+
+	It("should refuse the cached token to another private key of the App", func() {
+		source := newSource()
+		_, err := source.Token(AppID, keyPEM, RepoName)
+		Expect(err).NotTo(HaveOccurred())
+		other, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		otherPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(other)})
+		token, err := source.Token(AppID, otherPEM, RepoName)
+		Expect(err).To(HaveOccurred())
+		Expect(token).To(BeEmpty())
+		_, err = source.Token(AppID, []byte("not a key"), RepoName)
+		Expect(err).To(HaveOccurred())
+		Expect(minted).To(Equal(1))
+	})
+
+	It("should accept a PKCS#8 private key", func() {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = newSource().Token(AppID, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), RepoName)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should fail on a malformed private key", func() {
+		_, err := newSource().Token(AppID, []byte("not a key"), RepoName)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should fail when the App isn't installed on the repo", func() {
+		_, err := newSource().Token(AppID, keyPEM, RepoName+"2")
+		Expect(err).To(HaveOccurred())
+		Expect(minted).To(Equal(0))
+	})
+})
@@ -22,58 +22,345 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 func init() {
 	token = os.Getenv("GIT_TOKEN_GI") // store the github token you use in a secret and use it in the code by reading an env variable
+	appID = os.Getenv("GIT_APP_ID")   // or authenticate as a Github App, with its ID and PEM private key taken from the same secret
+	appPrivateKey = []byte(os.Getenv("GIT_APP_PRIVATE_KEY"))
+}
+
+// Client is the set of issue operations used by the reconciler, implemented for each provider -
+// RestClient for Github, GitlabClient for GitLab, GiteaClient for Gitea and JiraClient for Jira.
+// Each call returns the decoded issue (when the response has one), the HTTP status code and a transport error.
+// The codes are Github's, e.g. a successful LockIssue responds 204 No Content whatever the provider answers
+type Client interface {
+	CreateIssue(ownerRepo string, token string, issueData GithubSend) (GithubRecieve, int, error)
+	GetIssue(ownerRepo string, number int, token string) (GithubRecieve, int, error)
+	UpdateIssue(ownerRepo string, number int, token string, issueData GithubSend) (GithubRecieve, int, error)
+	CloseIssue(ownerRepo string, number int, token string) (GithubRecieve, int, error)
+	LockIssue(ownerRepo string, number int, token string) (int, error)
+	CreateComment(ownerRepo string, number int, token string, body string) (GithubComment, int, error)
+	ListComments(ownerRepo string, number int, query url.Values, token string) ([]GithubComment, int, error)
+	UpdateComment(ownerRepo string, number int, id int64, token string, body string) (GithubComment, int, error)
+	DeleteComment(ownerRepo string, number int, id int64, token string) (int, error)
+	SearchIssuesByTitle(ownerRepo string, title string, token string) ([]GithubRecieve, int, error)
+	ListIssues(ownerRepo string, query url.Values, token string) ([]GithubRecieve, int, error)
+}
+
+// RestClient implements Client with Github's REST API
+type RestClient struct {
+	// BaseURL is the API root, e.g. https://api.github.com or https://<host>/api/v3 for Github Enterprise
+	BaseURL    string
+	HTTPClient *http.Client
+	// RateLimits pauses the calls of a credential once its budget is exhausted, nil disables it
+	RateLimits *RateLimiter
+	// ETags makes GetIssue a conditional request for issues it fetched before, nil disables it
+	ETags *ETagCache
+	// GraphQLURL is the GraphQL endpoint of the host, https://api.github.com/graphql or https://<host>/api/graphql
+	GraphQLURL string
+	// Batches makes GetIssue read the issue from a batch of the repo's issues fetched with GraphQL, nil disables it
+	Batches *IssueBatches
+}
+
+// NewClient returns a RestClient for baseURL. An empty baseURL means DefaultBaseURL and a nil httpClient means http.DefaultClient
+func NewClient(baseURL string, httpClient *http.Client) *RestClient {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return &RestClient{BaseURL: baseURL, HTTPClient: httpClient, RateLimits: NewRateLimiter(), ETags: NewETagCache(), GraphQLURL: graphQLURL(baseURL)}
+}
+
+// CreateIssue opens a new issue in ownerRepo
+func (c *RestClient) CreateIssue(ownerRepo string, token string, issueData GithubSend) (GithubRecieve, int, error) {
+	return c.issueCall(ownerRepo, 0, token, issueData, "POST")
+}
+
+// GetIssue fetches issue number from ownerRepo. When it is unchanged since the last fetch Github responds 304 Not Modified,
+// and the cached issue is returned with Not_Modified_Code. With Batches it is read from the repo's batch when it has it
+func (c *RestClient) GetIssue(ownerRepo string, number int, token string) (GithubRecieve, int, error) {
+	if c.Batches != nil {
+		issue, code, ok, err := c.batchedIssue(ownerRepo, number, token)
+		if err != nil || ok {
+			return issue, code, err
+		}
+	}
+	return c.issueCall(ownerRepo, number, token, GithubSend{}, "GET")
+}
+
+// UpdateIssue edits the fields set in issueData of issue number
+func (c *RestClient) UpdateIssue(ownerRepo string, number int, token string, issueData GithubSend) (GithubRecieve, int, error) {
+	if issueData.Labels != nil && len(issueData.Labels) == 0 { // an empty list is left out of the PATCH, so the labels are removed first
+		resp, _, err := c.call("DELETE", "/repos/"+ownerRepo+"/issues/"+strconv.Itoa(number)+"/labels", nil, token)
+		if err != nil {
+			return GithubRecieve{}, 0, err
+		}
+		if resp.StatusCode != No_Content_Code {
+			return GithubRecieve{}, resp.StatusCode, nil
+		}
+	}
+	return c.issueCall(ownerRepo, number, token, issueData, "PATCH")
+}
+
+// CloseIssue changes the state of issue number into closed
+func (c *RestClient) CloseIssue(ownerRepo string, number int, token string) (GithubRecieve, int, error) {
+	return c.issueCall(ownerRepo, number, token, GithubSend{}, "CLOSE")
+}
+
+// LockIssue locks the conversation of issue number, Github responds 204 No Content
+func (c *RestClient) LockIssue(ownerRepo string, number int, token string) (int, error) {
+	resp, _, err := c.call("PUT", "/repos/"+ownerRepo+"/issues/"+strconv.Itoa(number)+"/lock", GithubLock{Reason: "resolved"}, token)
+	if err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+// CreateComment posts a comment with body on issue number
+func (c *RestClient) CreateComment(ownerRepo string, number int, token string, body string) (GithubComment, int, error) {
+	var comment GithubComment
+	resp, respBody, err := c.call("POST", "/repos/"+ownerRepo+"/issues/"+strconv.Itoa(number)+"/comments", GithubComment{Body: body}, token)
+	if err != nil {
+		return comment, 0, err
+	}
+	if resp.StatusCode == Created_Code {
+		if err := json.Unmarshal(respBody, &comment); err != nil {
+			return comment, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+	}
+	return comment, resp.StatusCode, nil
+}
+
+// ListComments returns one page of the comments of issue number, oldest first, query sets page and per_page - https://docs.github.com/en/rest/reference/issues#list-issue-comments
+func (c *RestClient) ListComments(ownerRepo string, number int, query url.Values, token string) ([]GithubComment, int, error) {
+	var comments []GithubComment
+	resp, body, err := c.call("GET", "/repos/"+ownerRepo+"/issues/"+strconv.Itoa(number)+"/comments?"+query.Encode(), nil, token)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode == Ok_Code {
+		if err := json.Unmarshal(body, &comments); err != nil {
+			return nil, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+	}
+	return comments, resp.StatusCode, nil
+}
+
+// UpdateComment replaces the body of comment id, Github finds it without the issue's number
+func (c *RestClient) UpdateComment(ownerRepo string, number int, id int64, token string, body string) (GithubComment, int, error) {
+	var comment GithubComment
+	resp, respBody, err := c.call("PATCH", "/repos/"+ownerRepo+"/issues/comments/"+strconv.FormatInt(id, 10), GithubComment{Body: body}, token)
+	if err != nil {
+		return comment, 0, err
+	}
+	if resp.StatusCode == Ok_Code {
+		if err := json.Unmarshal(respBody, &comment); err != nil {
+			return comment, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+	}
+	return comment, resp.StatusCode, nil
+}
+
+// DeleteComment deletes comment id, Github responds 204 No Content
+func (c *RestClient) DeleteComment(ownerRepo string, number int, id int64, token string) (int, error) {
+	resp, _, err := c.call("DELETE", "/repos/"+ownerRepo+"/issues/comments/"+strconv.FormatInt(id, 10), nil, token)
+	if err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+// SearchIssues returns the issues of ownerRepo matching query, in Github's search syntax - https://docs.github.com/en/rest/reference/search#search-issues-and-pull-requests
+func (c *RestClient) SearchIssues(ownerRepo string, query string, token string) ([]GithubRecieve, int, error) {
+	var found GithubSearch
+	q := url.Values{"q": {"repo:" + ownerRepo + " is:issue " + query}, "per_page": {"100"}}
+	resp, body, err := c.call("GET", "/search/issues?"+q.Encode(), nil, token)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode == Ok_Code {
+		if err := json.Unmarshal(body, &found); err != nil {
+			return nil, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+	}
+	return found.Items, resp.StatusCode, nil
+}
+
+// SearchIssuesByTitle returns the issues of ownerRepo whose title contains the words of title
+func (c *RestClient) SearchIssuesByTitle(ownerRepo string, title string, token string) ([]GithubRecieve, int, error) {
+	return c.SearchIssues(ownerRepo, "in:title "+strconv.Quote(title), token)
+}
+
+// ListIssues returns one page of ownerRepo's issues filtered by query (state, labels, since, page, per_page...) - https://docs.github.com/en/rest/reference/issues#list-repository-issues
+func (c *RestClient) ListIssues(ownerRepo string, query url.Values, token string) ([]GithubRecieve, int, error) {
+	var issues []GithubRecieve
+	resp, body, err := c.call("GET", "/repos/"+ownerRepo+"/issues?"+query.Encode(), nil, token)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode == Ok_Code {
+		if err := json.Unmarshal(body, &issues); err != nil {
+			return nil, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+	}
+	return issues, resp.StatusCode, nil
+}
+
+// issueCall makes the API call and decodes the response body for a successful call
+func (c *RestClient) issueCall(ownerRepo string, number int, token string, issueData GithubSend, apiType string) (GithubRecieve, int, error) {
+	var issue GithubRecieve
+	if apiType == "PATCH" || apiType == "CLOSE" {
+		c.ETags.forget(ownerRepo, number) // the cached version is outdated once the issue is edited
+		c.Batches.Forget(ownerRepo, number)
+	}
+	resp, body, err := c.GithubAPIcall(ownerRepo, issueData, number, token, apiType)
+	if err != nil {
+		return issue, 0, err
+	}
+	if apiType == "GET" && resp.StatusCode == Not_Modified_Code {
+		if cached, ok := c.ETags.cached(ownerRepo, number); ok {
+			return cached, Not_Modified_Code, nil
+		}
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := json.Unmarshal(body, &issue); err != nil {
+			return issue, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+		if apiType == "GET" {
+			c.ETags.store(ownerRepo, number, resp, issue)
+		}
+	}
+	return issue, resp.StatusCode, nil
+}
+
+// DefaultToken returns the operator's global token, used by GithubIssues without spec.credentialsSecretRef
+func DefaultToken() string {
+	return token
+}
+
+// DefaultAppCredentials returns the operator's global Github App ID and private key, the ID is empty when they aren't configured
+func DefaultAppCredentials() (string, []byte) {
+	return appID, appPrivateKey
 }
 
 ////////////////////////////////////////////////////////////////  Client FUNCTIONS  ////////////////////////////////////////////////////////////////
 
-// HttpHandler check for a mismatch between httpCode and the expected code, and update the Stauts accordingly
+// HttpHandler check for a mismatch between httpCode and the expected code, and update the Stauts accordingly.
+// The error is typed by the response, see StatusError
 func HttpHandler(githubi trainingv1alpha1.GithubIssue, httpCode int, expectedCode int, ownerRepo string) (trainingv1alpha1.GithubIssue, error) {
-	var err error
-	var errName string
-	switch httpCode {
-	case 404:
-		errName = ", Not Found"
-	case 403:
-		errName = ", Forbidden"
-	case 401:
-		errName = ", Unauthorized Client"
-	default:
-		errName = ""
-	}
-	if httpCode != expectedCode {
-		err = fmt.Errorf(" Repo - %s, Token - %s, bad HTTP response code - %d%s", ownerRepo, token, httpCode, errName)
+	err := StatusError(httpCode, expectedCode, ownerRepo)
+	if err != nil {
 		githubi.Status.State = Fail_Repo
 		githubi.Status.LastUpdateTimestamp = time.Now().String() // update LastUpdateTimestamp field
+		reason := FailureReason(err)
+		message := fmt.Sprintf("Github responded %d for repo %s", httpCode, ownerRepo)
+		switch reason {
+		case trainingv1alpha1.ReasonUnauthorized:
+			SetCondition(&githubi, trainingv1alpha1.ConditionCredentialsValid, metav1.ConditionFalse, reason, message)
+		case trainingv1alpha1.ReasonNotFound, trainingv1alpha1.ReasonForbidden:
+			SetCondition(&githubi, trainingv1alpha1.ConditionRepoAccessible, metav1.ConditionFalse, reason, message)
+		}
+		SetCondition(&githubi, trainingv1alpha1.ConditionSynced, metav1.ConditionFalse, reason, message)
+	} else {
+		SetCondition(&githubi, trainingv1alpha1.ConditionCredentialsValid, metav1.ConditionTrue, trainingv1alpha1.ReasonSucceeded, "Github accepted the token")
+		SetCondition(&githubi, trainingv1alpha1.ConditionRepoAccessible, metav1.ConditionTrue, trainingv1alpha1.ReasonSucceeded, "Repo "+ownerRepo+" is accessible")
 	} // if -status error
 	return githubi, err
 }
 
-// DeleteIssue check if FinalizerName has been registered, make a REST API call to close the Issue,
+// SetCondition sets the condition conditionType of githubi, its LastTransitionTime changes only with its status.
+// Anything formatted like a Github token is redacted from message
+func SetCondition(githubi *trainingv1alpha1.GithubIssue, conditionType string, status metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(&githubi.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            Redact(message),
+		ObservedGeneration: githubi.Generation,
+	})
+}
+
+// setRequestFailed sets the Synced condition of a call which got no usable response, RateLimited when a rate limit held it back
+func setRequestFailed(githubi *trainingv1alpha1.GithubIssue, err error) {
+	SetCondition(githubi, trainingv1alpha1.ConditionSynced, metav1.ConditionFalse, FailureReason(err), err.Error())
+}
+
+// SetReadyCondition derives the Ready condition from the other conditions, it is false with the reason of the first one which isn't true
+func SetReadyCondition(githubi *trainingv1alpha1.GithubIssue) {
+	for _, conditionType := range []string{trainingv1alpha1.ConditionCredentialsValid, trainingv1alpha1.ConditionRepoAccessible, trainingv1alpha1.ConditionSynced} {
+		condition := meta.FindStatusCondition(githubi.Status.Conditions, conditionType)
+		if condition == nil {
+			SetCondition(githubi, trainingv1alpha1.ConditionReady, metav1.ConditionFalse, trainingv1alpha1.ReasonNotSynced, conditionType+" is unknown")
+			return
+		}
+		if condition.Status != metav1.ConditionTrue {
+			SetCondition(githubi, trainingv1alpha1.ConditionReady, metav1.ConditionFalse, condition.Reason, condition.Message)
+			return
+		}
+	}
+	SetCondition(githubi, trainingv1alpha1.ConditionReady, metav1.ConditionTrue, trainingv1alpha1.ReasonSucceeded, "The issue is synced with Github")
+}
+
+// DeleteIssue check if FinalizerName has been registered, then handle the Issue by githubi's deletion policy -
+// close it (optionally commenting first or locking it after), or leave it as is with Orphan,
 // then check http response and eventually unregister FinalizerName
-func DeleteIssue(githubi trainingv1alpha1.GithubIssue, ownerRepo string) (trainingv1alpha1.GithubIssue, error) {
+func DeleteIssue(gc Client, githubi trainingv1alpha1.GithubIssue, ownerRepo string, token string) (trainingv1alpha1.GithubIssue, error) {
 	var err error
 	if ContainsString(githubi.GetFinalizers(), FinalizerName) { // https://book.kubebuilder.io/reference/using-finalizers.html
+		policy := githubi.Spec.DeletionPolicy
+		if policy == trainingv1alpha1.DeletionPolicyOrphan || githubi.Status.Number == 0 {
+			// nothing to do on Github, the issue is left as is (or was never created)
+			controllerutil.RemoveFinalizer(&githubi, FinalizerName)
+			return githubi, nil
+		}
+		if policy == trainingv1alpha1.DeletionPolicyCommentAndClose {
+			_, code, err := gc.CreateComment(ownerRepo, githubi.Status.Number, token, ClosingComment(githubi))
+			if err != nil {
+				setRequestFailed(&githubi, err)
+				return githubi, fmt.Errorf("%v: %w", COMMENT, requestError(ownerRepo, 0, err))
+			}
+			if githubi, err = HttpHandler(githubi, code, Created_Code, ownerRepo); err != nil {
+				return githubi, fmt.Errorf("%v: %w", COMMENT, err)
+			}
+		}
+		changed := githubi.Status.State != "closed" // an issue closed on Github already is closed again, but nothing changed
 		githubi.Status.State = "closed"
 		// send an API call to change the state and closing time of the Github Issue
-		resp, _, err := GithubAPIcall(ownerRepo, githubi.Spec.Title, githubi.Spec.Description, githubi.Status.Number, token, "CLOSE")
+		_, code, err := gc.CloseIssue(ownerRepo, githubi.Status.Number, token)
 		if err != nil {
-			return githubi, fmt.Errorf("%v: %v :%w", PATCH, REST_ERROR, err) // wraping an error
+			setRequestFailed(&githubi, err)
+			return githubi, fmt.Errorf("%v: %w", PATCH, requestError(ownerRepo, 0, err))
 		}
-		if githubi, err = HttpHandler(githubi, resp.StatusCode, Ok_Code, ownerRepo); err != nil {
-			return githubi, fmt.Errorf("%v: %v :%w", PATCH, HTTP_ERROR, err)
-		} else {
-			// remove our finalizer from the list and update it.
-			controllerutil.RemoveFinalizer(&githubi, FinalizerName)
+		if githubi, err = HttpHandler(githubi, code, Ok_Code, ownerRepo); err != nil {
+			return githubi, fmt.Errorf("%v: %w", PATCH, err)
+		}
+		if policy == trainingv1alpha1.DeletionPolicyLock {
+			code, err := gc.LockIssue(ownerRepo, githubi.Status.Number, token)
+			if err != nil {
+				setRequestFailed(&githubi, err)
+				return githubi, fmt.Errorf("%v: %w", LOCK, requestError(ownerRepo, 0, err))
+			}
+			if githubi, err = HttpHandler(githubi, code, No_Content_Code, ownerRepo); err != nil {
+				return githubi, fmt.Errorf("%v: %w", LOCK, err)
+			}
+		}
+		// remove our finalizer from the list and update it.
+		controllerutil.RemoveFinalizer(&githubi, FinalizerName)
+		if changed {
 			githubi.Status.LastUpdateTimestamp = time.Now().String() // update LastUpdateTimestamp field
 		}
 	}
@@ -81,83 +368,402 @@ func DeleteIssue(githubi trainingv1alpha1.GithubIssue, ownerRepo string) (traini
 	// return result, nil // Stop reconciliation as the item is being deleted
 }
 
+// ClosingComment returns the comment posted by the CommentAndClose deletion policy
+func ClosingComment(githubi trainingv1alpha1.GithubIssue) string {
+	if githubi.Spec.ClosingComment != "" {
+		return githubi.Spec.ClosingComment
+	}
+	return fmt.Sprintf("Closing this issue, its GithubIssue %s/%s has been deleted.", githubi.Namespace, githubi.Name)
+}
+
 // GetIssue creates a githubissue or fetch and update.
-// Then it chcecks for errors of REST, bad token/repo or JSON and eventually update the K8s object
-func GetIssue(githubi trainingv1alpha1.GithubIssue, ownerRepo string, apiType string) (trainingv1alpha1.GithubIssue, error, bool) {
+// Then it chcecks for failed calls and unexpected responses, typed as in errors.go, and eventually update the K8s object
+func GetIssue(gc Client, githubi trainingv1alpha1.GithubIssue, ownerRepo string, token string, apiType string) (trainingv1alpha1.GithubIssue, error, bool) {
 	var issue GithubRecieve // Storing the github issue from Github website
 	var firstCall string
-	if apiType == "GET" {
-		firstCall = GET
-	} else {
-		firstCall = POST
-	}
-	resp, body, err := GithubAPIcall(ownerRepo, githubi.Spec.Title, githubi.Spec.Description, githubi.Status.Number, token, apiType)
-	if err != nil {
-		return githubi, fmt.Errorf("%v: %v :%w", firstCall, REST_ERROR, err), false
-	}
+	var code int
+	var err error
 	var expectedCode int
 	if apiType == "POST" {
+		firstCall = POST
 		expectedCode = Created_Code
+		createData := IssueData(githubi)
+		createData.State, createData.StateReason = "", "" // an issue is always created open, a closed spec.state is applied right after
+		issue, code, err = gc.CreateIssue(ownerRepo, token, createData)
 	} else {
+		firstCall = GET
 		expectedCode = Ok_Code
+		issue, code, err = gc.GetIssue(ownerRepo, githubi.Status.Number, token)
 	}
-	if githubi, err = HttpHandler(githubi, resp.StatusCode, expectedCode, ownerRepo); err != nil {
-		return githubi, fmt.Errorf("%v: %v :%w", firstCall, HTTP_ERROR, err), false
+	if err != nil {
+		setRequestFailed(&githubi, err)
+		return githubi, fmt.Errorf("%v: %w", firstCall, requestError(ownerRepo, 0, err)), false
 	}
-	if err := json.Unmarshal(body, &issue); err != nil {
-		return githubi, fmt.Errorf("%v: %v :%w", firstCall, JSON_ERROR, err), false
+	if code == Not_Modified_Code {
+		githubi, _ = HttpHandler(githubi, expectedCode, expectedCode, ownerRepo)
+		if synced := meta.FindStatusCondition(githubi.Status.Conditions, trainingv1alpha1.ConditionSynced); synced != nil &&
+			synced.Status == metav1.ConditionTrue && synced.ObservedGeneration == githubi.Generation && githubi.Status.State != Fail_Repo {
+			return githubi, nil, false // neither the issue nor the spec changed since the last successful sync
+		}
+		code = expectedCode // the spec changed or the last reconcile failed, compare it with the cached issue
+	}
+	if githubi, err = HttpHandler(githubi, code, expectedCode, ownerRepo); err != nil {
+		return githubi, fmt.Errorf("%v: %w", firstCall, err), false
 	}
 	if apiType == "POST" {
 		githubi.Status.Number = issue.Number // set the new issue number
 		githubi.Status.State = issue.State
 		githubi.Status.LastUpdateTimestamp = time.Now().String() // update LastUpdateTimestamp field
 	}
+	observeIssue(&githubi, issue)
 
-	if apiType == "GET" && githubi.Spec.Description != issue.Description {
-		// if there is a change in the description after pulling the issue from Github.com,
-		// then update the issue's description on the website with K8s issue's description
-		resp, body, err = GithubAPIcall(ownerRepo, githubi.Spec.Title, githubi.Spec.Description, githubi.Status.Number, token, "PATCH")
+	// if there is a change in the title, description, labels, assignees, milestone or state after pulling the issue from Github.com,
+	// then update the fields the spec wins on the website with K8s issue's spec, and report the ReportOnly ones
+	drifted := DriftedFields(githubi, issue)
+	for _, field := range drifted {
+		driftDetections.WithLabelValues(field, string(FieldSyncMode(githubi, field))).Inc()
+	}
+	updateData, enforce, reportOnly := enforcedData(githubi, drifted)
+	if len(reportOnly) > 0 {
+		SetCondition(&githubi, trainingv1alpha1.ConditionDrifted, metav1.ConditionTrue, trainingv1alpha1.ReasonDrifted,
+			"The issue differs from the spec on Github in "+strings.Join(reportOnly, ", "))
+	} else {
+		SetCondition(&githubi, trainingv1alpha1.ConditionDrifted, metav1.ConditionFalse, trainingv1alpha1.ReasonInSync, "No ReportOnly field differs from the spec")
+	}
+	if enforce {
+		issue, code, err = gc.UpdateIssue(ownerRepo, githubi.Status.Number, token, updateData)
 		if err != nil {
-			return githubi, fmt.Errorf("%v: %v :%w", PATCH, REST_ERROR, err), false
+			setRequestFailed(&githubi, err)
+			return githubi, fmt.Errorf("%v: %w", PATCH, requestError(ownerRepo, 0, err)), false
 		}
-		if githubi, err = HttpHandler(githubi, resp.StatusCode, expectedCode, ownerRepo); err != nil {
-			return githubi, fmt.Errorf("%v: %v :%w", PATCH, HTTP_ERROR, err), false
+		if githubi, err = HttpHandler(githubi, code, Ok_Code, ownerRepo); err != nil {
+			return githubi, fmt.Errorf("%v: %w", PATCH, err), false
 		}
+		observeIssue(&githubi, issue)
+		githubi.Status.LastUpdateTimestamp = time.Now().String() // update LastUpdateTimestamp field
+		SetCondition(&githubi, trainingv1alpha1.ConditionSynced, metav1.ConditionTrue, trainingv1alpha1.ReasonSucceeded, "The issue matches the spec")
 		return githubi, err, true // successfully updating the githubIssue in Github.com
 	}
+	SetCondition(&githubi, trainingv1alpha1.ConditionSynced, metav1.ConditionTrue, trainingv1alpha1.ReasonSucceeded, "The issue matches the spec")
 	return githubi, err, false
 }
 
+// FindIssueByMarker looks for an issue whose body carries githubi's marker among the issues updated since githubi was created
+func FindIssueByMarker(gc Client, githubi trainingv1alpha1.GithubIssue, ownerRepo string, token string) (trainingv1alpha1.GithubIssue, int, error) {
+	marker := Marker(githubi)
+	if marker == "" {
+		return githubi, 0, nil
+	}
+	query := url.Values{"state": {"all"}, "per_page": {strconv.Itoa(PerPage)}}
+	if !githubi.CreationTimestamp.IsZero() {
+		query.Set("since", githubi.CreationTimestamp.Add(-time.Minute).UTC().Format(time.RFC3339))
+	}
+	for page := 1; page <= MaxMarkerPages; page++ {
+		query.Set("page", strconv.Itoa(page))
+		issues, code, err := gc.ListIssues(ownerRepo, query, token)
+		if err != nil {
+			setRequestFailed(&githubi, err)
+			return githubi, 0, fmt.Errorf("%v: %w", LIST, requestError(ownerRepo, 0, err))
+		}
+		if githubi, err = HttpHandler(githubi, code, Ok_Code, ownerRepo); err != nil {
+			return githubi, 0, fmt.Errorf("%v: %w", LIST, err)
+		}
+		for _, issue := range issues {
+			if strings.Contains(issue.Description, marker) {
+				return githubi, issue.Number, nil
+			}
+		}
+		if len(issues) < PerPage {
+			break
+		}
+	}
+	return githubi, 0, nil
+}
+
+// ListAllIssues returns the issues of ownerRepo filtered by query (state, labels, since...), going through every page of PerPage issues.
+// The pull requests, which Github lists among the issues, are left out
+func ListAllIssues(gc Client, ownerRepo string, query url.Values, token string) ([]GithubRecieve, error) {
+	query = copyQuery(query)
+	query.Set("per_page", strconv.Itoa(PerPage))
+	var all []GithubRecieve
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		issues, code, err := gc.ListIssues(ownerRepo, query, token)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", LIST, requestError(ownerRepo, 0, err))
+		}
+		if err := StatusError(code, Ok_Code, ownerRepo); err != nil {
+			return nil, fmt.Errorf("%v: %w", LIST, err)
+		}
+		for _, issue := range issues {
+			if issue.PullRequest == nil {
+				all = append(all, issue)
+			}
+		}
+		if len(issues) < PerPage {
+			return all, nil
+		}
+	}
+}
+
+// Marker returns the hidden HTML comment which ties an issue's body to githubi's UID.
+// An adopted or imported GithubIssue has none - it is tied to its issue by status.number, and the issue's body is left as it is
+func Marker(githubi trainingv1alpha1.GithubIssue) string {
+	if githubi.UID == "" || githubi.Status.Adopted || githubi.Annotations[trainingv1alpha1.ImportedByAnnotation] != "" {
+		return ""
+	}
+	return "<!-- " + MarkerPrefix + string(githubi.UID) + " -->"
+}
+
+// IssueBody returns the issue's body for githubi - spec.description followed by githubi's marker
+func IssueBody(githubi trainingv1alpha1.GithubIssue) string {
+	if marker := Marker(githubi); marker != "" {
+		return githubi.Spec.Description + "\n\n" + marker
+	}
+	return githubi.Spec.Description
+}
+
+// FindIssueToAdopt returns the number of the existing issue githubi adopts - spec.issueNumber, an issue carrying githubi's marker,
+// or the issue titled spec.title with spec.adoptByTitle. status.adopted is set for spec.issueNumber and spec.adoptByTitle,
+// whose issues were written by someone else, so no marker is added to their body. Zero means there is nothing to adopt and a new issue should be created
+func FindIssueToAdopt(gc Client, githubi trainingv1alpha1.GithubIssue, ownerRepo string, token string) (trainingv1alpha1.GithubIssue, int, error) {
+	if githubi.Spec.IssueNumber > 0 {
+		githubi.Status.Adopted = true
+		return githubi, githubi.Spec.IssueNumber, nil
+	}
+	// an issue carrying githubi's marker was created by an earlier reconcile whose status update was lost
+	githubi, number, err := FindIssueByMarker(gc, githubi, ownerRepo, token)
+	if err != nil || number > 0 || !githubi.Spec.AdoptByTitle {
+		return githubi, number, err
+	}
+	issues, code, err := gc.SearchIssuesByTitle(ownerRepo, githubi.Spec.Title, token)
+	if err != nil {
+		setRequestFailed(&githubi, err)
+		return githubi, 0, fmt.Errorf("%v: %w", SEARCH, requestError(ownerRepo, 0, err))
+	}
+	if githubi, err = HttpHandler(githubi, code, Ok_Code, ownerRepo); err != nil {
+		return githubi, 0, fmt.Errorf("%v: %w", SEARCH, err)
+	}
+	for _, issue := range issues { // the search matches words of the title, so look for the exact title and take the oldest issue
+		if issue.Title == githubi.Spec.Title && (number == 0 || issue.Number < number) {
+			number = issue.Number
+		}
+	}
+	githubi.Status.Adopted = number > 0
+	return githubi, number, nil
+}
+
+// IssueData returns the fields of githubi's spec to send to Github, unset labels, assignees, milestone and state are left out.
+// Labels set to an empty list are sent as an empty, non-nil list, which every client removes the issue's labels for
+func IssueData(githubi trainingv1alpha1.GithubIssue) GithubSend {
+	return GithubSend{
+		Title:       githubi.Spec.Title,
+		Body:        IssueBody(githubi),
+		Labels:      githubi.Spec.Labels,
+		Assignees:   githubi.Spec.Assignees,
+		Milestone:   githubi.Spec.Milestone,
+		State:       githubi.Spec.State,
+		StateReason: githubi.Spec.StateReason,
+	}
+}
+
+// Drifted checks if the issue on Github differs from githubi's spec in the title, description or in the labels, assignees, milestone and state it sets
+func Drifted(githubi trainingv1alpha1.GithubIssue, issue GithubRecieve) bool {
+	return len(DriftedFields(githubi, issue)) > 0
+}
+
+// DriftedFields returns the fields of the issue on Github which differ from githubi's spec -
+// title, body, labels, assignees, milestone and state (for a differing state or stateReason)
+func DriftedFields(githubi trainingv1alpha1.GithubIssue, issue GithubRecieve) []string {
+	var fields []string
+	if githubi.Spec.Title != issue.Title {
+		fields = append(fields, FieldTitle)
+	}
+	if IssueBody(githubi) != issue.Description {
+		fields = append(fields, FieldBody)
+	}
+	if githubi.Spec.Labels != nil && !SameSet(githubi.Spec.Labels, issue.LabelNames()) { // an empty list drifts from any label
+		fields = append(fields, FieldLabels)
+	}
+	if len(githubi.Spec.Assignees) > 0 && !SameSet(githubi.Spec.Assignees, issue.AssigneeLogins()) {
+		fields = append(fields, FieldAssignees)
+	}
+	if githubi.Spec.Milestone != nil && *githubi.Spec.Milestone != issue.MilestoneNumber() {
+		fields = append(fields, FieldMilestone)
+	}
+	if (githubi.Spec.State != "" && githubi.Spec.State != issue.State) ||
+		(githubi.Spec.State == trainingv1alpha1.StateClosed && githubi.Spec.StateReason != "" && githubi.Spec.StateReason != issue.StateReason) {
+		fields = append(fields, FieldState)
+	}
+	return fields
+}
+
+// FieldSyncMode returns the SyncMode of field in githubi's spec.syncPolicy, KubernetesWins when it is unset
+func FieldSyncMode(githubi trainingv1alpha1.GithubIssue, field string) trainingv1alpha1.SyncMode {
+	var mode trainingv1alpha1.SyncMode
+	if policy := githubi.Spec.SyncPolicy; policy != nil {
+		switch field {
+		case FieldTitle:
+			mode = policy.Title
+		case FieldBody:
+			mode = policy.Body
+		case FieldLabels:
+			mode = policy.Labels
+		case FieldAssignees:
+			mode = policy.Assignees
+		case FieldState:
+			mode = policy.State
+		}
+	}
+	if mode == "" {
+		return trainingv1alpha1.SyncModeKubernetesWins
+	}
+	return mode
+}
+
+// enforcedData returns the fields to send to Github for overwriting the drifted fields which the spec wins,
+// and the ReportOnly fields among the drifted ones
+func enforcedData(githubi trainingv1alpha1.GithubIssue, drifted []string) (GithubSend, bool, []string) {
+	var data GithubSend
+	var enforce bool
+	var reportOnly []string
+	desired := IssueData(githubi)
+	for _, field := range drifted {
+		switch FieldSyncMode(githubi, field) {
+		case trainingv1alpha1.SyncModeGitHubWins:
+			continue
+		case trainingv1alpha1.SyncModeReportOnly:
+			reportOnly = append(reportOnly, field)
+			continue
+		}
+		enforce = true
+		switch field {
+		case FieldTitle:
+			data.Title = desired.Title
+		case FieldBody:
+			data.Body = desired.Body
+		case FieldLabels:
+			data.Labels = desired.Labels
+		case FieldAssignees:
+			data.Assignees = desired.Assignees
+		case FieldMilestone:
+			data.Milestone = desired.Milestone
+		case FieldState:
+			data.State, data.StateReason = desired.State, desired.StateReason
+		}
+	}
+	return data, enforce, reportOnly
+}
+
+// observeIssue reports the title, key, state, labels, assignees and milestone of the issue on Github in githubi's status,
+// and its description too when Github may win it
+func observeIssue(githubi *trainingv1alpha1.GithubIssue, issue GithubRecieve) {
+	githubi.Status.Title = issue.Title
+	githubi.Status.Key = issue.Key
+	githubi.Status.Description = ""
+	if FieldSyncMode(*githubi, FieldBody) != trainingv1alpha1.SyncModeKubernetesWins {
+		githubi.Status.Description = strings.TrimSuffix(strings.TrimSuffix(issue.Description, Marker(*githubi)), "\n\n")
+	}
+	if issue.State != "" {
+		githubi.Status.State = issue.State
+	}
+	githubi.Status.StateReason = issue.StateReason
+	githubi.Status.Labels = nil
+	if labels := issue.LabelNames(); len(labels) > 0 {
+		githubi.Status.Labels = labels
+	}
+	githubi.Status.Assignees = nil
+	if assignees := issue.AssigneeLogins(); len(assignees) > 0 {
+		githubi.Status.Assignees = assignees
+	}
+	githubi.Status.Milestone = issue.MilestoneNumber()
+}
+
+// SameSet checks if a and b hold the same strings, regardless of their order
+func SameSet(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int, len(a))
+	for _, item := range a {
+		counts[item]++
+	}
+	for _, item := range b {
+		if counts[item] == 0 {
+			return false
+		}
+		counts[item]--
+	}
+	return true
+}
+
 ////////////////////////////////////////////////////////////////  Other FUNCTIONS  ////////////////////////////////////////////////////////////////
 
-// GithubAPIcall makes a HTTP call based apiType variable to Github.com
-func GithubAPIcall(ownerRepo string, title string, description string, number int, token string, apiType string) (*http.Response, []byte, error) {
-	var issueData GithubSend
+// GithubAPIcall makes a HTTP call based apiType variable to the client's BaseURL, a GET is conditional on the cached version of the issue
+func (c *RestClient) GithubAPIcall(ownerRepo string, issueData GithubSend, number int, token string, apiType string) (*http.Response, []byte, error) {
 	if apiType == "CLOSE" {
 		issueData = GithubSend{State: "closed", ClosingTime: time.Now().Format("2006-01-02 15:04:05")} // formating time -> https://stackoverflow.com/questions/33119748/convert-time-time-to-string
 		apiType = "PATCH"
-	} else {
-		issueData = GithubSend{Title: title, Body: description}
 	}
-	jsonData, _ := json.Marshal(issueData)
-	//creating client to set custom headers for Authorization
-	client := &http.Client{}
-	var apiURL string
-	var req *http.Request
-	if apiType == "POST" {
-		apiURL = "https://api.github.com/repos/" + ownerRepo + "/issues"
-	} else {
-		apiURL = "https://api.github.com/repos/" + ownerRepo + "/issues/" + strconv.Itoa(number)
+	path := "/repos/" + ownerRepo + "/issues"
+	if apiType != "POST" {
+		path += "/" + strconv.Itoa(number)
+	}
+	if apiType == "GET" {
+		return c.request(apiType, path, nil, token, c.ETags.conditions(ownerRepo, number))
+	}
+	return c.call(apiType, path, issueData, token)
+}
+
+// call sends payload (if it isn't nil) as JSON to path under the client's BaseURL, and reads the whole response
+func (c *RestClient) call(method string, path string, payload interface{}, token string) (*http.Response, []byte, error) {
+	return c.request(method, path, payload, token, nil)
+}
+
+// request makes the call with the extra header set, the token is redacted from its error
+func (c *RestClient) request(method string, path string, payload interface{}, token string, header http.Header) (*http.Response, []byte, error) {
+	if !strings.HasPrefix(path, "https://") && !strings.HasPrefix(path, "http://") {
+		path = c.BaseURL + path
+	}
+	resp, body, err := c.send(method, path, payload, token, header)
+	return resp, body, RedactError(err, token)
+}
+
+// send makes the call of request to the whole URL
+func (c *RestClient) send(method string, url string, payload interface{}, token string, header http.Header) (*http.Response, []byte, error) {
+	reqBody := bytes.NewReader(nil)
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		reqBody = bytes.NewReader(jsonData)
+	}
+	if err := c.RateLimits.Wait(token); err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return nil, nil, err
 	}
-	req, _ = http.NewRequest(apiType, apiURL, bytes.NewReader(jsonData))
 	req.Header.Set("Authorization", "token "+token)
-	resp, err := client.Do(req)
-	if resp.Body != nil {
-		defer resp.Body.Close()
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	start := time.Now()
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		observeCall(c.BaseURL, method, 0, start)
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	observeCall(c.BaseURL, method, resp.StatusCode, start)
+	if err != nil {
+		return resp, body, err
 	}
-	body, _ := ioutil.ReadAll(resp.Body)
-	// fmt.Println("fmt - Hello from postORpatchIsuue, isPost =", isPost, ", status = ", resp.StatusCode, ", http.StatusCreated = ", http.StatusCreated, " and err = ", err) // fmt option
-	return resp, body, err
+	return resp, body, c.RateLimits.Observe(token, resp, body)
 }
 
 // Helper functions to check and remove string from a slice of string. From https://book.kubebuilder.io/reference/using-finalizers.html
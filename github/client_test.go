@@ -0,0 +1,421 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGithub(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Github Client Suite")
+}
+
+var _ = Describe("Github REST client", func() {
+	const RepoName = "razo7/githubissues-operator"
+	var (
+		server    *httptest.Server
+		requests  []*http.Request
+		bodies    []GithubSend
+		rawBodies []string
+		code      int
+		reply     GithubRecieve
+	)
+
+	BeforeEach(func() {
+		requests, bodies, rawBodies = nil, nil, nil
+		code, reply = http.StatusOK, GithubRecieve{Number: 7, State: "open", Title: "t", Description: "d"}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var data GithubSend
+			raw, _ := ioutil.ReadAll(req.Body)
+			_ = json.Unmarshal(raw, &data)
+			rawBodies = append(rawBodies, string(raw))
+			requests = append(requests, req)
+			bodies = append(bodies, data)
+			if req.Method == "PATCH" && code == http.StatusCreated {
+				w.WriteHeader(http.StatusOK) // an update right after a creation
+			} else if (strings.HasSuffix(req.URL.Path, "/lock") || req.Method == "DELETE") && code == http.StatusOK {
+				w.WriteHeader(http.StatusNoContent)
+			} else if strings.HasSuffix(req.URL.Path, "/comments") && code == http.StatusOK {
+				w.WriteHeader(http.StatusCreated)
+			} else {
+				w.WriteHeader(code)
+			}
+			_ = json.NewEncoder(w).Encode(reply)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should default to Github.com", func() {
+		Expect(NewClient("", nil).BaseURL).To(Equal(DefaultBaseURL))
+	})
+
+	It("should create an issue against the configured base URL", func() {
+		code = http.StatusCreated
+		issue, status, err := NewClient(server.URL+"/", server.Client()).CreateIssue(RepoName, "abc", GithubSend{Title: "t", Body: "d"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status).To(Equal(http.StatusCreated))
+		Expect(issue.Number).To(Equal(7))
+		Expect(requests[0].Method).To(Equal("POST"))
+		Expect(requests[0].URL.Path).To(Equal("/repos/" + RepoName + "/issues"))
+		Expect(requests[0].Header.Get("Authorization")).To(Equal("token abc"))
+		Expect(bodies[0].Title).To(Equal("t"))
+	})
+
+	It("should close an issue with a PATCH", func() {
+		_, status, err := NewClient(server.URL, server.Client()).CloseIssue(RepoName, 7, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(requests[0].Method).To(Equal("PATCH"))
+		Expect(requests[0].URL.Path).To(Equal("/repos/" + RepoName + "/issues/7"))
+		Expect(bodies[0].State).To(Equal("closed"))
+	})
+
+	It("should return the status code of a failed call", func() {
+		code = http.StatusNotFound
+		_, status, err := NewClient(server.URL, server.Client()).GetIssue(RepoName, 7, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status).To(Equal(http.StatusNotFound))
+	})
+
+	It("should return an error when the server is unreachable", func() {
+		gc := NewClient(server.URL, server.Client())
+		server.Close()
+		_, _, err := gc.GetIssue(RepoName, 7, "abc")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("reconciling through the Client interface", func() {
+		var githubi trainingv1alpha1.GithubIssue
+
+		BeforeEach(func() {
+			githubi = trainingv1alpha1.GithubIssue{Spec: trainingv1alpha1.GithubIssueSpec{Title: "t", Description: "new"}}
+		})
+
+		It("should record the number of a created issue", func() {
+			code = http.StatusCreated
+			githubi, err, _ := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "POST")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(githubi.Status.Number).To(Equal(7))
+			Expect(githubi.Status.State).To(Equal("open"))
+		})
+
+		It("should update a drifted description", func() {
+			githubi.Status.Number = 7
+			_, err, updated := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "GET")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			Expect(requests).To(HaveLen(2))
+			Expect(requests[1].Method).To(Equal("PATCH"))
+			Expect(bodies[1].Body).To(Equal("new"))
+		})
+
+		It("should send labels, assignees and milestone on create", func() {
+			code = http.StatusCreated
+			milestone := 2
+			githubi.Spec.Labels = []string{"bug"}
+			githubi.Spec.Assignees = []string{"razo7"}
+			githubi.Spec.Milestone = &milestone
+			reply.Labels = []GithubLabel{{Name: "bug"}}
+			reply.Assignees = []GithubUser{{Login: "razo7"}}
+			reply.Milestone = &GithubMilestone{Number: 2}
+			githubi, err, _ := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "POST")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bodies[0].Labels).To(Equal([]string{"bug"}))
+			Expect(bodies[0].Assignees).To(Equal([]string{"razo7"}))
+			Expect(*bodies[0].Milestone).To(Equal(2))
+			Expect(githubi.Status.Labels).To(Equal([]string{"bug"}))
+			Expect(githubi.Status.Assignees).To(Equal([]string{"razo7"}))
+			Expect(githubi.Status.Milestone).To(Equal(2))
+		})
+
+		It("should update drifted labels regardless of their order", func() {
+			githubi.Status.Number = 7
+			githubi.Spec.Description = "d"
+			githubi.Spec.Labels = []string{"bug", "ui"}
+			reply.Labels = []GithubLabel{{Name: "ui"}, {Name: "bug"}}
+			_, err, updated := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "GET")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+
+			reply.Labels = []GithubLabel{{Name: "bug"}}
+			_, err, updated = GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "GET")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			Expect(bodies[len(bodies)-1].Labels).To(Equal([]string{"bug", "ui"}))
+		})
+
+		It("should remove every label once spec.labels is an empty list", func() {
+			githubi.Status.Number = 7
+			githubi.Spec.Description = "d"
+			githubi.Spec.Labels = []string{}
+			reply.Labels = []GithubLabel{{Name: "bug"}}
+			_, err, updated := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "GET")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			Expect(requests).To(HaveLen(3))
+			Expect(requests[1].Method).To(Equal("DELETE"))
+			Expect(requests[1].URL.Path).To(Equal("/repos/" + RepoName + "/issues/7/labels"))
+			Expect(requests[2].Method).To(Equal("PATCH"))
+
+			reply.Labels = nil
+			Expect(Drifted(githubi, reply)).To(BeFalse())
+		})
+
+		It("should leave unset labels and assignees as they are on Github", func() {
+			githubi.Spec.Description = "d"
+			reply.Labels = []GithubLabel{{Name: "triaged"}}
+			Expect(Drifted(githubi, reply)).To(BeFalse())
+		})
+
+		It("should close an issue once spec.state is closed", func() {
+			githubi.Status.Number = 7
+			githubi.Spec.Description = "d"
+			githubi.Spec.State = trainingv1alpha1.StateClosed
+			githubi.Spec.StateReason = "not_planned"
+			githubi, err, updated := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "GET")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			Expect(bodies[1].State).To(Equal(trainingv1alpha1.StateClosed))
+			Expect(bodies[1].StateReason).To(Equal("not_planned"))
+			Expect(githubi.Status.State).To(Equal("open")) // as replied by the test server
+		})
+
+		It("should create a closed issue open and then close it", func() {
+			code = http.StatusCreated
+			githubi.Spec.Description = "d"
+			githubi.Spec.State = trainingv1alpha1.StateClosed
+			_, err, _ := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "POST")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bodies[0].State).To(BeEmpty())
+			Expect(requests[1].Method).To(Equal("PATCH"))
+			Expect(bodies[1].State).To(Equal(trainingv1alpha1.StateClosed))
+		})
+
+		It("should reopen a closed issue once spec.state is open", func() {
+			githubi.Spec.Description = "d"
+			githubi.Spec.State = trainingv1alpha1.StateOpen
+			Expect(Drifted(githubi, GithubRecieve{Title: "t", Description: "d", State: "closed"})).To(BeTrue())
+			Expect(Drifted(githubi, GithubRecieve{Title: "t", Description: "d", State: "open"})).To(BeFalse())
+			githubi.Spec.State = ""
+			Expect(Drifted(githubi, GithubRecieve{Title: "t", Description: "d", State: "closed"})).To(BeFalse())
+		})
+
+		It("should keep a GitHubWins body and report it in the status", func() {
+			githubi.Status.Number = 7
+			githubi.Spec.SyncPolicy = &trainingv1alpha1.SyncPolicy{Body: trainingv1alpha1.SyncModeGitHubWins}
+			githubi, err, updated := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "GET")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+			Expect(requests).To(HaveLen(1))
+			Expect(githubi.Status.Description).To(Equal("d"))
+			Expect(meta.IsStatusConditionFalse(githubi.Status.Conditions, trainingv1alpha1.ConditionDrifted)).To(BeTrue())
+		})
+
+		It("should raise the Drifted condition for a ReportOnly title and overwrite the rest", func() {
+			githubi.Status.Number = 7
+			githubi.Spec.Title = "spec title"
+			githubi.Spec.SyncPolicy = &trainingv1alpha1.SyncPolicy{Title: trainingv1alpha1.SyncModeReportOnly}
+			githubi, err, updated := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "GET")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeTrue())
+			Expect(bodies[1].Title).To(BeEmpty())
+			Expect(bodies[1].Body).To(Equal("new"))
+			drifted := meta.FindStatusCondition(githubi.Status.Conditions, trainingv1alpha1.ConditionDrifted)
+			Expect(drifted.Status).To(Equal(metav1.ConditionTrue))
+			Expect(drifted.Message).To(ContainSubstring(FieldTitle))
+			Expect(githubi.Status.Title).To(Equal("t"))
+		})
+
+		It("should adopt spec.issueNumber without searching", func() {
+			githubi.Spec.IssueNumber = 3
+			_, number, err := FindIssueToAdopt(NewClient(server.URL, server.Client()), githubi, RepoName, "abc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(number).To(Equal(3))
+			Expect(requests).To(BeEmpty())
+		})
+
+		It("should leave the body of an adopted issue without a marker", func() {
+			githubi.UID = "1234-abcd"
+			githubi.Spec.IssueNumber, githubi.Spec.Description = 7, "d"
+			githubi, number, err := FindIssueToAdopt(NewClient(server.URL, server.Client()), githubi, RepoName, "abc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(githubi.Status.Adopted).To(BeTrue())
+			githubi.Status.Number = number
+			githubi, err, updated := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "GET")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeFalse())
+			Expect(requests).To(HaveLen(1)) // the body written by someone else isn't rewritten to add a marker
+			Expect(Drifted(githubi, GithubRecieve{Title: "t", Description: "d"})).To(BeFalse())
+		})
+
+		It("should adopt the issue carrying the UID marker instead of creating a duplicate", func() {
+			githubi.UID = "1234-abcd"
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				requests = append(requests, req)
+				_ = json.NewEncoder(w).Encode([]GithubRecieve{{Number: 5, Description: "other"}, {Number: 6, Description: IssueBody(githubi)}})
+			})
+			_, number, err := FindIssueToAdopt(NewClient(server.URL, server.Client()), githubi, RepoName, "abc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(number).To(Equal(6))
+			Expect(requests[0].URL.Path).To(Equal("/repos/" + RepoName + "/issues"))
+			Expect(requests[0].URL.Query().Get("state")).To(Equal("all"))
+		})
+
+		It("should send the UID marker in the body and not see it as drift", func() {
+			githubi.UID = "1234-abcd"
+			data := IssueData(githubi)
+			Expect(data.Body).To(HavePrefix("new"))
+			Expect(data.Body).To(ContainSubstring("<!-- " + MarkerPrefix + "1234-abcd -->"))
+			Expect(Drifted(githubi, GithubRecieve{Title: "t", Description: data.Body})).To(BeFalse())
+			Expect(Drifted(githubi, GithubRecieve{Title: "t", Description: "new"})).To(BeTrue())
+		})
+
+		It("should append no more than the webhook leaves room for", func() {
+			githubi.UID = "0b4c0a1e-3b7a-4c1e-9f3e-6d2a5e8c7b91"
+			Expect(len(IssueBody(githubi)) - len(githubi.Spec.Description)).To(Equal(trainingv1alpha1.MaxMarkerLength))
+		})
+
+		It("should adopt the oldest issue with the exact title", func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				requests = append(requests, req)
+				_ = json.NewEncoder(w).Encode(GithubSearch{Items: []GithubRecieve{{Number: 9, Title: "t"}, {Number: 4, Title: "t"}, {Number: 2, Title: "t - old"}}})
+			})
+			githubi.Spec.AdoptByTitle = true
+			_, number, err := FindIssueToAdopt(NewClient(server.URL, server.Client()), githubi, RepoName, "abc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(number).To(Equal(4))
+			Expect(requests[0].URL.Path).To(Equal("/search/issues"))
+			Expect(requests[0].URL.Query().Get("q")).To(Equal(`repo:` + RepoName + ` is:issue in:title "t"`))
+		})
+
+		It("should create a new issue when no title matches", func() {
+			reply = GithubRecieve{}
+			githubi.Spec.AdoptByTitle = true
+			_, number, err := FindIssueToAdopt(NewClient(server.URL, server.Client()), githubi, RepoName, "abc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(number).To(Equal(0))
+		})
+
+		Context("deleting the GithubIssue", func() {
+			BeforeEach(func() {
+				githubi.Name, githubi.Namespace = "issue", "default"
+				githubi.Status.Number = 7
+				githubi.Finalizers = []string{FinalizerName}
+			})
+
+			It("should close the issue by default", func() {
+				githubi, err := DeleteIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(requests).To(HaveLen(1))
+				Expect(bodies[0].State).To(Equal("closed"))
+				Expect(githubi.Finalizers).To(BeEmpty())
+			})
+
+			It("should leave the issue as is with Orphan", func() {
+				githubi.Spec.DeletionPolicy = trainingv1alpha1.DeletionPolicyOrphan
+				githubi, err := DeleteIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(requests).To(BeEmpty())
+				Expect(githubi.Finalizers).To(BeEmpty())
+			})
+
+			It("should close and lock the issue with Lock", func() {
+				githubi.Spec.DeletionPolicy = trainingv1alpha1.DeletionPolicyLock
+				githubi, err := DeleteIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(requests).To(HaveLen(2))
+				Expect(requests[1].Method).To(Equal("PUT"))
+				Expect(requests[1].URL.Path).To(Equal("/repos/" + RepoName + "/issues/7/lock"))
+				Expect(githubi.Finalizers).To(BeEmpty())
+			})
+
+			It("should comment before closing with CommentAndClose", func() {
+				githubi.Spec.DeletionPolicy = trainingv1alpha1.DeletionPolicyCommentAndClose
+				githubi.Spec.ClosingComment = "deploy of v1.4 finished"
+				githubi, err := DeleteIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(requests).To(HaveLen(2))
+				Expect(requests[0].URL.Path).To(Equal("/repos/" + RepoName + "/issues/7/comments"))
+				Expect(rawBodies[0]).To(ContainSubstring("deploy of v1.4 finished"))
+				Expect(bodies[1].State).To(Equal("closed"))
+				Expect(githubi.Finalizers).To(BeEmpty())
+			})
+
+			It("should keep the timestamp of an issue which is closed already", func() {
+				githubi.Status.State, githubi.Status.LastUpdateTimestamp = "closed", "then"
+				githubi, err := DeleteIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(githubi.Status.LastUpdateTimestamp).To(Equal("then"))
+				Expect(githubi.Finalizers).To(BeEmpty())
+			})
+
+			It("should keep the finalizer when closing fails", func() {
+				code = http.StatusForbidden
+				githubi, err := DeleteIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc")
+				Expect(err).To(HaveOccurred())
+				Expect(githubi.Finalizers).To(ContainElement(FinalizerName))
+			})
+		})
+
+		It("should mark the repo as failed on a bad response", func() {
+			code = http.StatusUnauthorized
+			githubi, err, _ := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "POST")
+			Expect(err).To(HaveOccurred())
+			Expect(githubi.Status.State).To(Equal(Fail_Repo))
+			credentials := meta.FindStatusCondition(githubi.Status.Conditions, trainingv1alpha1.ConditionCredentialsValid)
+			Expect(credentials).NotTo(BeNil())
+			Expect(credentials.Status).To(Equal(metav1.ConditionFalse))
+			Expect(credentials.Reason).To(Equal(trainingv1alpha1.ReasonUnauthorized))
+			SetReadyCondition(&githubi)
+			Expect(meta.IsStatusConditionFalse(githubi.Status.Conditions, trainingv1alpha1.ConditionReady)).To(BeTrue())
+		})
+
+		It("should report a missing repo and a rate limit with their reasons", func() {
+			code = http.StatusNotFound
+			githubi, _, _ := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "POST")
+			Expect(meta.FindStatusCondition(githubi.Status.Conditions, trainingv1alpha1.ConditionRepoAccessible).Reason).To(Equal(trainingv1alpha1.ReasonNotFound))
+			code = http.StatusTooManyRequests
+			githubi, _, _ = GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "POST")
+			Expect(meta.FindStatusCondition(githubi.Status.Conditions, trainingv1alpha1.ConditionSynced).Reason).To(Equal(trainingv1alpha1.ReasonRateLimited))
+		})
+
+		It("should be ready after a successful sync", func() {
+			githubi.Status.Number = 7
+			githubi.Spec.Description = "d"
+			githubi, err, _ := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "GET")
+			Expect(err).NotTo(HaveOccurred())
+			SetReadyCondition(&githubi)
+			Expect(meta.IsStatusConditionTrue(githubi.Status.Conditions, trainingv1alpha1.ConditionSynced)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(githubi.Status.Conditions, trainingv1alpha1.ConditionReady)).To(BeTrue())
+		})
+	})
+})
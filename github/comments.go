@@ -0,0 +1,179 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+)
+
+// SyncComments makes the comments of githubi's issue match spec.comments - it creates the missing comments, edits the ones
+// whose body changed and, with spec.pruneComments, deletes the ones whose key was removed.
+// The ID of every comment is recorded in status.comments, and a comment posted by a reconcile whose status update was lost
+// is found again by its marker, so a comment is never posted twice. It returns true when a comment was changed on Github
+func SyncComments(gc Client, githubi trainingv1alpha1.GithubIssue, ownerRepo string, token string) (trainingv1alpha1.GithubIssue, bool, error) {
+	var changed bool
+	var marked map[string]GithubComment // the comments carrying a marker of githubi, listed once a key has no ID
+	posted := make(map[string]trainingv1alpha1.CommentStatus, len(githubi.Status.Comments))
+	for _, comment := range githubi.Status.Comments {
+		posted[comment.Key] = comment
+	}
+	synced := make([]trainingv1alpha1.CommentStatus, 0, len(githubi.Spec.Comments))
+	// failed keeps the IDs of the comments which weren't synced yet, along with the ones which were
+	failed := func(githubi trainingv1alpha1.GithubIssue, err error) (trainingv1alpha1.GithubIssue, bool, error) {
+		for _, comment := range githubi.Status.Comments {
+			if current, ok := posted[comment.Key]; ok {
+				synced = append(synced, current)
+			}
+		}
+		githubi.Status.Comments = synced
+		return githubi, changed, err
+	}
+	for _, desired := range githubi.Spec.Comments {
+		hash := commentHash(desired.Body)
+		current, ok := posted[desired.Key]
+		if !ok && marked == nil {
+			var err error
+			if githubi, marked, err = markedComments(gc, githubi, ownerRepo, token); err != nil {
+				return failed(githubi, err)
+			}
+		}
+		if found, isMarked := marked[desired.Key]; !ok && isMarked { // posted before, but its ID was never recorded
+			current = trainingv1alpha1.CommentStatus{Key: desired.Key, ID: found.ID}
+			if found.Body == CommentBody(githubi, desired) {
+				current.BodyHash = hash
+			}
+		}
+		if current.ID != 0 && current.BodyHash != hash {
+			comment, code, err := gc.UpdateComment(ownerRepo, githubi.Status.Number, current.ID, token, CommentBody(githubi, desired))
+			if err != nil {
+				setRequestFailed(&githubi, err)
+				return failed(githubi, fmt.Errorf("%v: %w", EDIT_COMMENT, requestError(ownerRepo, 0, err)))
+			}
+			if code == 404 { // deleted on Github, post it again
+				current.ID = 0
+			} else {
+				if githubi, err = HttpHandler(githubi, code, Ok_Code, ownerRepo); err != nil {
+					return failed(githubi, fmt.Errorf("%v: %w", EDIT_COMMENT, err))
+				}
+				current = trainingv1alpha1.CommentStatus{Key: desired.Key, ID: comment.ID, BodyHash: hash}
+				changed = true
+			}
+		}
+		if current.ID == 0 {
+			comment, code, err := gc.CreateComment(ownerRepo, githubi.Status.Number, token, CommentBody(githubi, desired))
+			if err != nil {
+				setRequestFailed(&githubi, err)
+				return failed(githubi, fmt.Errorf("%v: %w", COMMENT, requestError(ownerRepo, 0, err)))
+			}
+			if githubi, err = HttpHandler(githubi, code, Created_Code, ownerRepo); err != nil {
+				return failed(githubi, fmt.Errorf("%v: %w", COMMENT, err))
+			}
+			current = trainingv1alpha1.CommentStatus{Key: desired.Key, ID: comment.ID, BodyHash: hash}
+			changed = true
+		}
+		delete(posted, desired.Key)
+		synced = append(synced, current)
+	}
+	// the keys left in posted were removed from spec.comments, their comments are deleted or left on the issue and forgotten
+	for _, removed := range githubi.Status.Comments {
+		if _, ok := posted[removed.Key]; !ok || !githubi.Spec.PruneComments {
+			continue
+		}
+		code, err := gc.DeleteComment(ownerRepo, githubi.Status.Number, removed.ID, token)
+		if err != nil {
+			setRequestFailed(&githubi, err)
+			return failed(githubi, fmt.Errorf("%v: %w", DELETE_COMMENT, requestError(ownerRepo, 0, err)))
+		}
+		if code != 404 { // a comment deleted on Github already is done with
+			if githubi, err = HttpHandler(githubi, code, No_Content_Code, ownerRepo); err != nil {
+				return failed(githubi, fmt.Errorf("%v: %w", DELETE_COMMENT, err))
+			}
+			changed = true
+		}
+		delete(posted, removed.Key)
+	}
+	githubi.Status.Comments = nil
+	if len(synced) > 0 {
+		githubi.Status.Comments = synced
+	}
+	return githubi, changed, nil
+}
+
+// markedComments returns the comments of githubi's issue which carry a marker of githubi, by key
+func markedComments(gc Client, githubi trainingv1alpha1.GithubIssue, ownerRepo string, token string) (trainingv1alpha1.GithubIssue, map[string]GithubComment, error) {
+	marked := map[string]GithubComment{}
+	prefix := CommentMarkerPrefix(githubi)
+	if prefix == "" {
+		return githubi, marked, nil
+	}
+	query := url.Values{"per_page": {strconv.Itoa(PerPage)}}
+	for page := 1; page <= MaxMarkerPages; page++ {
+		query.Set("page", strconv.Itoa(page))
+		comments, code, err := gc.ListComments(ownerRepo, githubi.Status.Number, query, token)
+		if err != nil {
+			setRequestFailed(&githubi, err)
+			return githubi, nil, fmt.Errorf("%v: %w", LIST_COMMENTS, requestError(ownerRepo, 0, err))
+		}
+		if githubi, err = HttpHandler(githubi, code, Ok_Code, ownerRepo); err != nil {
+			return githubi, nil, fmt.Errorf("%v: %w", LIST_COMMENTS, err)
+		}
+		for _, comment := range comments {
+			if start := strings.Index(comment.Body, prefix); start >= 0 {
+				key := comment.Body[start+len(prefix):]
+				if end := strings.Index(key, " -->"); end >= 0 {
+					key = key[:end]
+				}
+				if _, ok := marked[key]; !ok { // the oldest comment of a key wins
+					marked[key] = comment
+				}
+			}
+		}
+		if len(comments) < PerPage {
+			break
+		}
+	}
+	return githubi, marked, nil
+}
+
+// CommentMarkerPrefix returns the start of the markers of githubi's comments, the key and " -->" follow it
+func CommentMarkerPrefix(githubi trainingv1alpha1.GithubIssue) string {
+	if githubi.UID == "" {
+		return ""
+	}
+	return "<!-- " + MarkerPrefix + string(githubi.UID) + CommentMarkerKey
+}
+
+// CommentBody returns the body posted for comment - its body from spec.comments followed by its marker
+func CommentBody(githubi trainingv1alpha1.GithubIssue, comment trainingv1alpha1.IssueComment) string {
+	if prefix := CommentMarkerPrefix(githubi); prefix != "" {
+		return comment.Body + "\n\n" + prefix + comment.Key + " -->"
+	}
+	return comment.Body
+}
+
+// commentHash returns the hex SHA-256 of a comment's body
+func commentHash(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+)
+
+var _ = Describe("Declarative comments", func() {
+	const RepoName = "razo7/githubissues-operator"
+	var (
+		server   *httptest.Server
+		mu       sync.Mutex
+		comments map[int64]GithubComment // the comments of issue 7, by ID
+		nextID   int64
+		calls    []string
+		githubi  trainingv1alpha1.GithubIssue
+	)
+
+	BeforeEach(func() {
+		comments, nextID, calls = map[int64]GithubComment{}, 100, nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, req.Method)
+			var data GithubComment
+			_ = json.NewDecoder(req.Body).Decode(&data)
+			switch {
+			case req.URL.Path == "/repos/"+RepoName+"/issues/7/comments" && req.Method == "GET":
+				listed := []GithubComment{}
+				for id := int64(100); id < nextID; id++ {
+					if comment, ok := comments[id]; ok {
+						listed = append(listed, comment)
+					}
+				}
+				_ = json.NewEncoder(w).Encode(listed)
+			case req.URL.Path == "/repos/"+RepoName+"/issues/7/comments" && req.Method == "POST":
+				comment := GithubComment{ID: nextID, Body: data.Body}
+				comments[nextID] = comment
+				nextID++
+				w.WriteHeader(http.StatusCreated)
+				_ = json.NewEncoder(w).Encode(comment)
+			case strings.HasPrefix(req.URL.Path, "/repos/"+RepoName+"/issues/comments/"):
+				id, _ := strconv.ParseInt(strings.TrimPrefix(req.URL.Path, "/repos/"+RepoName+"/issues/comments/"), 10, 64)
+				comment, ok := comments[id]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				if req.Method == "DELETE" {
+					delete(comments, id)
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				comment.Body = data.Body
+				comments[id] = comment
+				_ = json.NewEncoder(w).Encode(comment)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		githubi = trainingv1alpha1.GithubIssue{Spec: trainingv1alpha1.GithubIssueSpec{
+			Title:    "t",
+			Comments: []trainingv1alpha1.IssueComment{{Key: "deploy", Body: "deploy of v1.4 finished"}},
+		}}
+		githubi.UID = "0b6b6e2c-6f6b-4d7c-9a43-3f0e3c1f6f9e"
+		githubi.Status.Number = 7
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	bodies := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		var found []string
+		for _, comment := range comments {
+			found = append(found, comment.Body)
+		}
+		return found
+	}
+
+	It("should create a missing comment once and record its ID", func() {
+		gc := NewClient(server.URL, server.Client())
+		githubi, changed, err := SyncComments(gc, githubi, RepoName, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(githubi.Status.Comments).To(HaveLen(1))
+		Expect(githubi.Status.Comments[0].Key).To(Equal("deploy"))
+		Expect(githubi.Status.Comments[0].ID).To(Equal(int64(100)))
+		Expect(bodies()).To(ConsistOf(CommentBody(githubi, githubi.Spec.Comments[0])))
+
+		calls = nil
+		githubi, changed, err = SyncComments(gc, githubi, RepoName, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeFalse())
+		Expect(calls).To(BeEmpty())
+	})
+
+	It("should find a comment whose ID was lost by its marker instead of posting it twice", func() {
+		gc := NewClient(server.URL, server.Client())
+		posted, _, err := SyncComments(gc, githubi, RepoName, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		githubi, changed, err := SyncComments(gc, githubi, RepoName, "abc") // the status with the ID was never stored
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeFalse())
+		Expect(githubi.Status.Comments).To(Equal(posted.Status.Comments))
+		Expect(bodies()).To(HaveLen(1))
+	})
+
+	It("should edit a comment whose body changed", func() {
+		gc := NewClient(server.URL, server.Client())
+		githubi, _, err := SyncComments(gc, githubi, RepoName, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		githubi.Spec.Comments[0].Body = "deploy of v1.5 finished"
+		githubi, changed, err := SyncComments(gc, githubi, RepoName, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(githubi.Status.Comments[0].ID).To(Equal(int64(100)))
+		Expect(bodies()).To(ConsistOf(ContainSubstring("deploy of v1.5 finished")))
+	})
+
+	It("should post a comment deleted on Github again", func() {
+		gc := NewClient(server.URL, server.Client())
+		githubi, _, err := SyncComments(gc, githubi, RepoName, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		mu.Lock()
+		comments = map[int64]GithubComment{}
+		mu.Unlock()
+		githubi.Spec.Comments[0].Body = "deploy of v1.5 finished"
+		githubi, _, err = SyncComments(gc, githubi, RepoName, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(githubi.Status.Comments[0].ID).To(Equal(int64(101)))
+		Expect(bodies()).To(ConsistOf(ContainSubstring("deploy of v1.5 finished")))
+	})
+
+	It("should delete a removed comment only with pruneComments", func() {
+		gc := NewClient(server.URL, server.Client())
+		githubi.Spec.Comments = append(githubi.Spec.Comments, trainingv1alpha1.IssueComment{Key: "rollback", Body: "rolled back"})
+		githubi, _, err := SyncComments(gc, githubi, RepoName, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bodies()).To(HaveLen(2))
+
+		githubi.Spec.Comments = githubi.Spec.Comments[:1]
+		githubi.Spec.PruneComments = true
+		githubi, changed, err := SyncComments(gc, githubi, RepoName, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(bodies()).To(ConsistOf(ContainSubstring("deploy of v1.4 finished")))
+		Expect(githubi.Status.Comments).To(HaveLen(1))
+
+		githubi.Spec.Comments = nil
+		githubi.Spec.PruneComments = false
+		githubi, changed, err = SyncComments(gc, githubi, RepoName, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeFalse())
+		Expect(bodies()).To(HaveLen(1)) // left on the issue and forgotten
+		Expect(githubi.Status.Comments).To(BeEmpty())
+	})
+
+	It("should keep the recorded IDs when a call fails", func() {
+		gc := NewClient(server.URL, server.Client())
+		githubi, _, err := SyncComments(gc, githubi, RepoName, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		recorded := githubi.Status.Comments
+		githubi.Spec.Comments[0].Body = "deploy of v1.5 finished"
+		githubi.Status.Number = 8 // a new key would be posted to a missing issue
+		githubi.Spec.Comments = append(githubi.Spec.Comments, trainingv1alpha1.IssueComment{Key: "rollback", Body: "rolled back"})
+		githubi, _, err = SyncComments(gc, githubi, RepoName, "abc")
+		Expect(err).To(HaveOccurred())
+		Expect(IsTerminal(err)).To(BeTrue())
+		Expect(githubi.Status.Comments).To(HaveLen(1))
+		Expect(githubi.Status.Comments[0].ID).To(Equal(recorded[0].ID))
+	})
+})
@@ -0,0 +1,144 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+)
+
+// NotFoundError is a 404 Not Found or 410 Gone response - the repo or the issue doesn't exist, or the token can't see it
+type NotFoundError struct {
+	OwnerRepo string
+	Code      int
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("repo %s: Github responded %d, not found", e.OwnerRepo, e.Code)
+}
+
+// AuthError is a 401 Unauthorized or 403 Forbidden response which isn't a rate limit - Github rejected the token or its permissions
+type AuthError struct {
+	OwnerRepo string
+	Code      int
+}
+
+func (e *AuthError) Error() string {
+	if e.Code == 401 {
+		return fmt.Sprintf("repo %s: Github responded %d, the token is invalid", e.OwnerRepo, e.Code)
+	}
+	return fmt.Sprintf("repo %s: Github responded %d, the token has no access", e.OwnerRepo, e.Code)
+}
+
+// ValidationError is a 400 Bad Request or 422 Unprocessable Entity response - Github refused the fields sent,
+// e.g. an unknown assignee or milestone
+type ValidationError struct {
+	OwnerRepo string
+	Code      int
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("repo %s: Github responded %d, the request was rejected as invalid", e.OwnerRepo, e.Code)
+}
+
+// RequestError is a call which got no usable response - a transport failure (Code is zero), a server error,
+// an unexpected status code or a body which can't be decoded. Retrying it may succeed
+type RequestError struct {
+	OwnerRepo string
+	Code      int
+	Err       error
+}
+
+func (e *RequestError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("repo %s: Github responded %d", e.OwnerRepo, e.Code)
+	}
+	if e.Code == 0 {
+		return fmt.Sprintf("repo %s: %v", e.OwnerRepo, e.Err)
+	}
+	return fmt.Sprintf("repo %s: Github responded %d: %v", e.OwnerRepo, e.Code, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError returns the typed error of a response with code instead of expectedCode, nil when they match
+func StatusError(code int, expectedCode int, ownerRepo string) error {
+	if code == expectedCode {
+		return nil
+	}
+	switch code {
+	case 404, 410:
+		return &NotFoundError{OwnerRepo: ownerRepo, Code: code}
+	case 401, 403:
+		return &AuthError{OwnerRepo: ownerRepo, Code: code}
+	case 400, 422:
+		return &ValidationError{OwnerRepo: ownerRepo, Code: code}
+	case 429: // the RateLimiter reports it first, unless the client has none
+		return &RateLimitError{ResetAt: time.Now().Add(RateLimitBackoff)}
+	}
+	return &RequestError{OwnerRepo: ownerRepo, Code: code}
+}
+
+// IsTerminal checks if err won't go away by retrying - a missing repo, rejected credentials or invalid fields,
+// which need a change of the spec, the credentials or the repo first
+func IsTerminal(err error) bool {
+	var notFound *NotFoundError
+	var auth *AuthError
+	var invalid *ValidationError
+	return errors.As(err, &notFound) || errors.As(err, &auth) || errors.As(err, &invalid)
+}
+
+// FailureReason returns the condition and Event reason of err
+func FailureReason(err error) string {
+	var notFound *NotFoundError
+	var auth *AuthError
+	var invalid *ValidationError
+	var limited *RateLimitError
+	var request *RequestError
+	switch {
+	case errors.As(err, &limited):
+		return trainingv1alpha1.ReasonRateLimited
+	case errors.As(err, &notFound):
+		return trainingv1alpha1.ReasonNotFound
+	case errors.As(err, &auth):
+		if auth.Code == 401 {
+			return trainingv1alpha1.ReasonUnauthorized
+		}
+		return trainingv1alpha1.ReasonForbidden
+	case errors.As(err, &invalid):
+		return trainingv1alpha1.ReasonValidationFailed
+	case errors.As(err, &request) && request.Code != 0:
+		return trainingv1alpha1.ReasonUnexpectedResponse
+	}
+	return trainingv1alpha1.ReasonRequestFailed
+}
+
+// requestError types err as a RequestError of a call to ownerRepo which got code (zero without a response),
+// unless it is typed already
+func requestError(ownerRepo string, code int, err error) error {
+	var limited *RateLimitError
+	var request *RequestError
+	if errors.As(err, &limited) || errors.As(err, &request) {
+		return err
+	}
+	return &RequestError{OwnerRepo: ownerRepo, Code: code, Err: err}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+var _ = Describe("Github error taxonomy", func() {
+	const RepoName = "razo7/githubissues-operator"
+
+	It("should type an unexpected response by its code", func() {
+		for _, expected := range []struct {
+			code     int
+			reason   string
+			terminal bool
+		}{
+			{http.StatusNotFound, trainingv1alpha1.ReasonNotFound, true},
+			{http.StatusGone, trainingv1alpha1.ReasonNotFound, true},
+			{http.StatusUnauthorized, trainingv1alpha1.ReasonUnauthorized, true},
+			{http.StatusForbidden, trainingv1alpha1.ReasonForbidden, true},
+			{http.StatusUnprocessableEntity, trainingv1alpha1.ReasonValidationFailed, true},
+			{http.StatusTooManyRequests, trainingv1alpha1.ReasonRateLimited, false},
+			{http.StatusBadGateway, trainingv1alpha1.ReasonUnexpectedResponse, false},
+		} {
+			err := StatusError(expected.code, http.StatusOK, RepoName)
+			Expect(err).To(HaveOccurred())
+			Expect(FailureReason(err)).To(Equal(expected.reason), "code %d", expected.code)
+			Expect(IsTerminal(err)).To(Equal(expected.terminal), "code %d", expected.code)
+		}
+	})
+
+	It("should return no error for the expected code", func() {
+		Expect(StatusError(http.StatusCreated, http.StatusCreated, RepoName)).To(Succeed())
+	})
+
+	Context("through the reconciler's calls", func() {
+		var (
+			server  *httptest.Server
+			code    int
+			githubi trainingv1alpha1.GithubIssue
+		)
+
+		BeforeEach(func() {
+			code = http.StatusOK
+			githubi = trainingv1alpha1.GithubIssue{Spec: trainingv1alpha1.GithubIssueSpec{Title: "t", Description: "d"}}
+			githubi.Status.Number = 7
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(code)
+				_, _ = w.Write([]byte(`{"message": "failure"}`))
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should return a NotFoundError for a missing repo", func() {
+			code = http.StatusNotFound
+			_, err, _ := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "GET")
+			var notFound *NotFoundError
+			Expect(errors.As(err, &notFound)).To(BeTrue())
+			Expect(notFound.OwnerRepo).To(Equal(RepoName))
+			Expect(IsTerminal(err)).To(BeTrue())
+		})
+
+		It("should return an AuthError when closing with a rejected token", func() {
+			code = http.StatusUnauthorized
+			githubi.Finalizers = []string{FinalizerName}
+			_, err := DeleteIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc")
+			var auth *AuthError
+			Expect(errors.As(err, &auth)).To(BeTrue())
+			Expect(FailureReason(err)).To(Equal(trainingv1alpha1.ReasonUnauthorized))
+		})
+
+		It("should return a ValidationError for rejected fields", func() {
+			code = http.StatusUnprocessableEntity
+			_, err, _ := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "POST")
+			var invalid *ValidationError
+			Expect(errors.As(err, &invalid)).To(BeTrue())
+		})
+
+		It("should return a retryable RequestError for a body which can't be decoded", func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				_, _ = w.Write([]byte("not json"))
+			})
+			githubi, err, _ := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "GET")
+			var request *RequestError
+			Expect(errors.As(err, &request)).To(BeTrue())
+			Expect(request.Code).To(Equal(http.StatusOK))
+			Expect(IsTerminal(err)).To(BeFalse())
+			synced := meta.FindStatusCondition(githubi.Status.Conditions, trainingv1alpha1.ConditionSynced)
+			Expect(synced).NotTo(BeNil())
+			Expect(synced.Reason).To(Equal(trainingv1alpha1.ReasonUnexpectedResponse))
+		})
+
+		It("should return a retryable RequestError when Github is unreachable", func() {
+			gc := NewClient(server.URL, server.Client())
+			server.Close()
+			githubi, err, _ := GetIssue(gc, githubi, RepoName, "abc", "GET")
+			var request *RequestError
+			Expect(errors.As(err, &request)).To(BeTrue())
+			Expect(request.Code).To(BeZero())
+			Expect(IsTerminal(err)).To(BeFalse())
+			synced := meta.FindStatusCondition(githubi.Status.Conditions, trainingv1alpha1.ConditionSynced)
+			Expect(synced.Reason).To(Equal(trainingv1alpha1.ReasonRequestFailed))
+		})
+	})
+})
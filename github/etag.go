@@ -0,0 +1,101 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// ETagCache keeps the last fetched version of every issue with its ETag and Last-Modified validators, so a resync can make a
+// conditional request - https://docs.github.com/en/rest/overview/resources-in-the-rest-api#conditional-requests
+// An unchanged issue is answered with 304 Not Modified, which doesn't count against the rate limit.
+type ETagCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedIssue // ownerRepo#number -> the last fetched issue
+}
+
+type cachedIssue struct {
+	etag         string
+	lastModified string
+	issue        GithubRecieve
+}
+
+// NewETagCache returns an empty ETagCache
+func NewETagCache() *ETagCache {
+	return &ETagCache{entries: map[string]cachedIssue{}}
+}
+
+// conditions returns the validators to send when fetching issue number of ownerRepo, none when it isn't cached or the cache is nil
+func (e *ETagCache) conditions(ownerRepo string, number int) http.Header {
+	header := http.Header{}
+	if e == nil {
+		return header
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if cached, ok := e.entries[etagKey(ownerRepo, number)]; ok {
+		if cached.etag != "" {
+			header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+	return header
+}
+
+// cached returns the issue a 304 response stands for
+func (e *ETagCache) cached(ownerRepo string, number int) (GithubRecieve, bool) {
+	if e == nil {
+		return GithubRecieve{}, false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	cached, ok := e.entries[etagKey(ownerRepo, number)]
+	return cached.issue, ok
+}
+
+// store caches issue with the validators of resp, an issue without validators isn't cached
+func (e *ETagCache) store(ownerRepo string, number int, resp *http.Response, issue GithubRecieve) {
+	if e == nil {
+		return
+	}
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if etag == "" && lastModified == "" {
+		delete(e.entries, etagKey(ownerRepo, number))
+		return
+	}
+	e.entries[etagKey(ownerRepo, number)] = cachedIssue{etag: etag, lastModified: lastModified, issue: issue}
+}
+
+// forget drops issue number of ownerRepo, once the operator changes it
+func (e *ETagCache) forget(ownerRepo string, number int) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.entries, etagKey(ownerRepo, number))
+}
+
+func etagKey(ownerRepo string, number int) string {
+	return ownerRepo + "#" + strconv.Itoa(number)
+}
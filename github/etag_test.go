@@ -0,0 +1,121 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+)
+
+var _ = Describe("Github conditional requests", func() {
+	const RepoName = "razo7/githubissues-operator"
+	var (
+		server   *httptest.Server
+		client   *RestClient
+		issue    GithubRecieve
+		version  int
+		methods  []string
+		notMod   int
+		matchers []string
+	)
+
+	BeforeEach(func() {
+		issue = GithubRecieve{Number: 1, Title: "t", Description: "d", State: "open"}
+		version, methods, notMod, matchers = 1, nil, 0, nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			methods = append(methods, req.Method)
+			etag := `"v` + strconv.Itoa(version) + `"`
+			if req.Method == "PATCH" {
+				var data GithubSend
+				_ = json.NewDecoder(req.Body).Decode(&data)
+				issue.Description = data.Body
+				version++
+				_ = json.NewEncoder(w).Encode(issue)
+				return
+			}
+			matchers = append(matchers, req.Header.Get("If-None-Match"))
+			if req.Header.Get("If-None-Match") == etag {
+				notMod++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			_ = json.NewEncoder(w).Encode(issue)
+		}))
+		client = NewClient(server.URL, server.Client())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should return the cached issue on 304 Not Modified", func() {
+		fetched, code, err := client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(Ok_Code))
+		again, code, err := client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(Not_Modified_Code))
+		Expect(again).To(Equal(fetched))
+		Expect(matchers).To(Equal([]string{"", `"v1"`}))
+	})
+
+	It("should forget the cached issue once it is edited", func() {
+		_, _, err := client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = client.UpdateIssue(RepoName, 1, "a-token", GithubSend{Body: "edited"})
+		Expect(err).NotTo(HaveOccurred())
+		fetched, code, err := client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(Ok_Code))
+		Expect(fetched.Description).To(Equal("edited"))
+		Expect(matchers[1]).To(BeEmpty())
+	})
+
+	It("should skip the comparison of an unchanged issue and spec", func() {
+		issue.Description = "edited on Github"
+		githubi := trainingv1alpha1.GithubIssue{
+			Spec:   trainingv1alpha1.GithubIssueSpec{Title: "t", Description: "d"},
+			Status: trainingv1alpha1.GithubIssueStatus{Number: 1, State: "open"},
+		}
+		githubi, err, _ := GetIssue(client, githubi, RepoName, "a-token", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(methods).To(Equal([]string{"GET", "PATCH"})) // the description is enforced
+		githubi, err, _ = GetIssue(client, githubi, RepoName, "a-token", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		githubi, err, updated := GetIssue(client, githubi, RepoName, "a-token", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated).To(BeFalse())
+		Expect(notMod).To(Equal(1))
+		Expect(methods).To(Equal([]string{"GET", "PATCH", "GET", "GET"}))
+
+		By("compare the cached issue with a changed spec")
+		githubi.Generation++
+		githubi.Spec.Description = "changed"
+		_, err, updated = GetIssue(client, githubi, RepoName, "a-token", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated).To(BeTrue())
+		Expect(notMod).To(Equal(2))
+		Expect(issue.Description).To(HavePrefix("changed"))
+	})
+})
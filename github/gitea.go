@@ -0,0 +1,316 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+)
+
+// GiteaClient implements Client with the v1 REST API of Gitea and Forgejo - https://try.gitea.io/api/swagger.
+// Its issues are much like Github's, but labels and milestones are sent by their IDs, which are looked up by name,
+// and an edited issue responds 201 Created, returned as Github's 200 OK
+type GiteaClient struct {
+	// BaseURL is the API root, https://<host>/api/v1
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewGiteaClient returns a GiteaClient for baseURL, a nil httpClient means http.DefaultClient
+func NewGiteaClient(baseURL string, httpClient *http.Client) *GiteaClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &GiteaClient{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: httpClient}
+}
+
+// giteaIssue is an issue as Gitea returns it
+type giteaIssue struct {
+	Number    int             `json:"number"`
+	Title     string          `json:"title"`
+	Body      string          `json:"body"`
+	State     string          `json:"state"` // open or closed
+	Labels    []giteaLabel    `json:"labels"`
+	Assignees []GithubUser    `json:"assignees"`
+	Milestone *giteaMilestone `json:"milestone"`
+	HTMLURL   string          `json:"html_url"`
+}
+
+// giteaLabel is a label of a repo
+type giteaLabel struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// giteaMilestone is a milestone of a repo, identified by its ID
+type giteaMilestone struct {
+	ID    int    `json:"id"`
+	Title string `json:"title,omitempty"`
+}
+
+// giteaIssueData - the fields of an issue sent to Gitea, unset fields are left as is
+type giteaIssueData struct {
+	Title     string   `json:"title,omitempty"`
+	Body      string   `json:"body,omitempty"`
+	Labels    []int64  `json:"labels,omitempty"` // only when creating, an issue's labels are replaced by replaceLabels
+	Assignees []string `json:"assignees,omitempty"`
+	Milestone int      `json:"milestone,omitempty"`
+	State     string   `json:"state,omitempty"`
+}
+
+// githubIssue translates issue into a Github issue, the number of its milestone is the milestone's ID
+func (issue giteaIssue) githubIssue() GithubRecieve {
+	translated := GithubRecieve{
+		Repo:        issue.HTMLURL,
+		Title:       issue.Title,
+		Description: issue.Body,
+		State:       issue.State,
+		Number:      issue.Number,
+		Assignees:   issue.Assignees,
+	}
+	for _, label := range issue.Labels {
+		translated.Labels = append(translated.Labels, GithubLabel{Name: label.Name})
+	}
+	if issue.Milestone != nil {
+		translated.Milestone = &GithubMilestone{Number: issue.Milestone.ID, Title: issue.Milestone.Title}
+	}
+	return translated
+}
+
+// CreateIssue opens a new issue in ownerRepo
+func (c *GiteaClient) CreateIssue(ownerRepo string, token string, issueData GithubSend) (GithubRecieve, int, error) {
+	data := giteaIssueData{Title: issueData.Title, Body: issueData.Body, Assignees: issueData.Assignees}
+	if issueData.Milestone != nil {
+		data.Milestone = *issueData.Milestone
+	}
+	if len(issueData.Labels) > 0 {
+		ids, code, err := c.labelIDs(ownerRepo, issueData.Labels, token)
+		if err != nil || code != 0 {
+			return GithubRecieve{}, code, err
+		}
+		data.Labels = ids
+	}
+	return c.issueCall(ownerRepo, "POST", "/repos/"+ownerRepo+"/issues", data, token)
+}
+
+// GetIssue fetches issue number from ownerRepo
+func (c *GiteaClient) GetIssue(ownerRepo string, number int, token string) (GithubRecieve, int, error) {
+	return c.issueCall(ownerRepo, "GET", "/repos/"+ownerRepo+"/issues/"+strconv.Itoa(number), nil, token)
+}
+
+// UpdateIssue edits the fields set in issueData of issue number, its labels are replaced first when they are set, an empty list removes them
+func (c *GiteaClient) UpdateIssue(ownerRepo string, number int, token string, issueData GithubSend) (GithubRecieve, int, error) {
+	if issueData.Labels != nil {
+		code, err := c.replaceLabels(ownerRepo, number, issueData.Labels, token)
+		if err != nil || code != Ok_Code {
+			return GithubRecieve{}, code, err
+		}
+	}
+	data := giteaIssueData{Title: issueData.Title, Body: issueData.Body, Assignees: issueData.Assignees, State: issueData.State}
+	if issueData.Milestone != nil {
+		data.Milestone = *issueData.Milestone
+	}
+	return c.editIssue(ownerRepo, number, data, token)
+}
+
+// CloseIssue changes the state of issue number into closed
+func (c *GiteaClient) CloseIssue(ownerRepo string, number int, token string) (GithubRecieve, int, error) {
+	return c.editIssue(ownerRepo, number, giteaIssueData{State: trainingv1alpha1.StateClosed}, token)
+}
+
+// LockIssue responds 204 No Content without a call - Gitea has no API for locking an issue's conversation,
+// so the Lock deletion policy only closes the issue
+func (c *GiteaClient) LockIssue(ownerRepo string, number int, token string) (int, error) {
+	return No_Content_Code, nil
+}
+
+// CreateComment posts a comment with body on issue number
+func (c *GiteaClient) CreateComment(ownerRepo string, number int, token string, body string) (GithubComment, int, error) {
+	return c.commentCall(ownerRepo, "POST", "/repos/"+ownerRepo+"/issues/"+strconv.Itoa(number)+"/comments", GithubComment{Body: body}, token)
+}
+
+// ListComments returns every comment of issue number on the first page, and none on the next ones - Gitea doesn't page them
+func (c *GiteaClient) ListComments(ownerRepo string, number int, query url.Values, token string) ([]GithubComment, int, error) {
+	var comments []GithubComment
+	if page, _ := strconv.Atoi(query.Get("page")); page > 1 {
+		return comments, Ok_Code, nil
+	}
+	resp, body, err := c.call("GET", "/repos/"+ownerRepo+"/issues/"+strconv.Itoa(number)+"/comments", nil, token)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode == Ok_Code {
+		if err := json.Unmarshal(body, &comments); err != nil {
+			return nil, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+	}
+	return comments, resp.StatusCode, nil
+}
+
+// UpdateComment replaces the body of comment id, Gitea finds it without the issue's number
+func (c *GiteaClient) UpdateComment(ownerRepo string, number int, id int64, token string, body string) (GithubComment, int, error) {
+	return c.commentCall(ownerRepo, "PATCH", "/repos/"+ownerRepo+"/issues/comments/"+strconv.FormatInt(id, 10), GithubComment{Body: body}, token)
+}
+
+// DeleteComment deletes comment id, Gitea responds 204 No Content
+func (c *GiteaClient) DeleteComment(ownerRepo string, number int, id int64, token string) (int, error) {
+	resp, _, err := c.call("DELETE", "/repos/"+ownerRepo+"/issues/comments/"+strconv.FormatInt(id, 10), nil, token)
+	if err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+// SearchIssuesByTitle returns the issues of ownerRepo matching title by Gitea's keyword search
+func (c *GiteaClient) SearchIssuesByTitle(ownerRepo string, title string, token string) ([]GithubRecieve, int, error) {
+	return c.listIssues(ownerRepo, url.Values{"q": {title}, "state": {"all"}, "limit": {strconv.Itoa(PerPage)}}, token)
+}
+
+// ListIssues returns one page of ownerRepo's issues filtered by query, in Github's terms (state, labels, since, page, per_page...)
+func (c *GiteaClient) ListIssues(ownerRepo string, query url.Values, token string) ([]GithubRecieve, int, error) {
+	query = copyQuery(query)
+	if perPage := query.Get("per_page"); perPage != "" {
+		query.Del("per_page")
+		query.Set("limit", perPage)
+	}
+	return c.listIssues(ownerRepo, query, token)
+}
+
+// listIssues returns one page of ownerRepo's issues, without its pull requests, filtered by query in Gitea's terms
+func (c *GiteaClient) listIssues(ownerRepo string, query url.Values, token string) ([]GithubRecieve, int, error) {
+	var issues []giteaIssue
+	query.Set("type", "issues")
+	resp, body, err := c.call("GET", "/repos/"+ownerRepo+"/issues?"+query.Encode(), nil, token)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode == Ok_Code {
+		if err := json.Unmarshal(body, &issues); err != nil {
+			return nil, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+	}
+	translated := make([]GithubRecieve, 0, len(issues))
+	for _, issue := range issues {
+		translated = append(translated, issue.githubIssue())
+	}
+	return translated, resp.StatusCode, nil
+}
+
+// editIssue edits issue number with data, Gitea's 201 Created is returned as Github's 200 OK
+func (c *GiteaClient) editIssue(ownerRepo string, number int, data giteaIssueData, token string) (GithubRecieve, int, error) {
+	issue, code, err := c.issueCall(ownerRepo, "PATCH", "/repos/"+ownerRepo+"/issues/"+strconv.Itoa(number), data, token)
+	if code == Created_Code {
+		code = Ok_Code
+	}
+	return issue, code, err
+}
+
+// replaceLabels sets the labels of issue number to names, it returns 422 as Github does when a label doesn't exist
+func (c *GiteaClient) replaceLabels(ownerRepo string, number int, names []string, token string) (int, error) {
+	ids := []int64{} // sent as an empty list, which removes every label
+	if len(names) > 0 {
+		var code int
+		var err error
+		if ids, code, err = c.labelIDs(ownerRepo, names, token); err != nil || code != 0 {
+			return code, err
+		}
+	}
+	resp, _, err := c.call("PUT", "/repos/"+ownerRepo+"/issues/"+strconv.Itoa(number)+"/labels", map[string][]int64{"labels": ids}, token)
+	if err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+// labelIDs looks the IDs of the labels names of ownerRepo up.
+// A non-zero code is the failed response of the lookup - 422, as Github's, when a label doesn't exist
+func (c *GiteaClient) labelIDs(ownerRepo string, names []string, token string) ([]int64, int, error) {
+	byName := map[string]int64{}
+	query := url.Values{"limit": {strconv.Itoa(PerPage)}}
+	for page := 1; page <= MaxMarkerPages; page++ {
+		var labels []giteaLabel
+		query.Set("page", strconv.Itoa(page))
+		resp, body, err := c.call("GET", "/repos/"+ownerRepo+"/labels?"+query.Encode(), nil, token)
+		if err != nil {
+			return nil, 0, err
+		}
+		if resp.StatusCode != Ok_Code {
+			return nil, resp.StatusCode, nil
+		}
+		if err := json.Unmarshal(body, &labels); err != nil {
+			return nil, 0, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+		for _, label := range labels {
+			byName[label.Name] = label.ID
+		}
+		if len(labels) < PerPage {
+			break
+		}
+	}
+	ids := make([]int64, 0, len(names))
+	for _, name := range names {
+		id, ok := byName[name]
+		if !ok {
+			return nil, 422, nil
+		}
+		ids = append(ids, id)
+	}
+	return ids, 0, nil
+}
+
+// issueCall makes the call and decodes the issue of a successful response
+func (c *GiteaClient) issueCall(ownerRepo string, method string, path string, payload interface{}, token string) (GithubRecieve, int, error) {
+	var issue giteaIssue
+	resp, body, err := c.call(method, path, payload, token)
+	if err != nil {
+		return GithubRecieve{}, 0, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := json.Unmarshal(body, &issue); err != nil {
+			return GithubRecieve{}, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+	}
+	return issue.githubIssue(), resp.StatusCode, nil
+}
+
+// commentCall makes the call and decodes the comment of a successful response
+func (c *GiteaClient) commentCall(ownerRepo string, method string, path string, payload interface{}, token string) (GithubComment, int, error) {
+	var comment GithubComment
+	resp, body, err := c.call(method, path, payload, token)
+	if err != nil {
+		return comment, 0, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := json.Unmarshal(body, &comment); err != nil {
+			return comment, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+	}
+	return comment, resp.StatusCode, nil
+}
+
+// call sends payload (if it isn't nil) as JSON to path under the client's BaseURL and reads the whole response,
+// authenticated with Gitea's token Authorization header. The token is redacted from its error
+func (c *GiteaClient) call(method string, path string, payload interface{}, token string) (*http.Response, []byte, error) {
+	resp, body, err := sendJSON(c.HTTPClient, c.BaseURL, method, path, payload, http.Header{"Authorization": {"token " + token}})
+	return resp, body, RedactError(err, token)
+}
@@ -0,0 +1,382 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+)
+
+// GitlabClient implements Client with GitLab's v4 REST API - https://docs.gitlab.com/ee/api/issues.html.
+// The owner/repo of a repo is the path of its GitLab project and an issue's number is its IID, the number shown in the project.
+// The issues are translated into Github's, and the status codes too where GitLab's differ
+type GitlabClient struct {
+	// BaseURL is the API root, e.g. https://gitlab.com/api/v4 or https://<host>/api/v4 for a self-managed GitLab
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewGitlabClient returns a GitlabClient for baseURL. An empty baseURL means DefaultGitlabURL and a nil httpClient means http.DefaultClient
+func NewGitlabClient(baseURL string, httpClient *http.Client) *GitlabClient {
+	if baseURL == "" {
+		baseURL = DefaultGitlabURL
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &GitlabClient{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: httpClient}
+}
+
+// gitlabIssue is an issue as GitLab returns it
+type gitlabIssue struct {
+	IID         int              `json:"iid"`
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	State       string           `json:"state"` // opened or closed
+	Labels      []string         `json:"labels"`
+	Assignees   []gitlabUser     `json:"assignees"`
+	Milestone   *gitlabMilestone `json:"milestone"`
+	WebURL      string           `json:"web_url"`
+}
+
+// gitlabUser is an assignee of an issue, or a user found by its username
+type gitlabUser struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+// gitlabMilestone is a milestone of a project, its IID is the number shown in the project
+type gitlabMilestone struct {
+	ID    int    `json:"id"`
+	IID   int    `json:"iid"`
+	Title string `json:"title,omitempty"`
+}
+
+// gitlabNote is a comment of an issue
+type gitlabNote struct {
+	ID   int64  `json:"id,omitempty"`
+	Body string `json:"body"`
+}
+
+// gitlabIssueData - the fields of an issue sent to GitLab, unset fields are left as is
+type gitlabIssueData struct {
+	Title            string  `json:"title,omitempty"`
+	Description      string  `json:"description,omitempty"`
+	Labels           *string `json:"labels,omitempty"` // comma separated, an empty string removes every label
+	AssigneeIDs      []int   `json:"assignee_ids,omitempty"`
+	MilestoneID      int     `json:"milestone_id,omitempty"`
+	StateEvent       string  `json:"state_event,omitempty"` // close or reopen
+	DiscussionLocked bool    `json:"discussion_locked,omitempty"`
+}
+
+// githubIssue translates issue into a Github issue
+func (issue gitlabIssue) githubIssue() GithubRecieve {
+	translated := GithubRecieve{
+		Repo:        issue.WebURL,
+		Title:       issue.Title,
+		Description: issue.Description,
+		State:       githubState(issue.State),
+		Number:      issue.IID,
+	}
+	for _, label := range issue.Labels {
+		translated.Labels = append(translated.Labels, GithubLabel{Name: label})
+	}
+	for _, assignee := range issue.Assignees {
+		translated.Assignees = append(translated.Assignees, GithubUser{Login: assignee.Username})
+	}
+	if issue.Milestone != nil {
+		translated.Milestone = &GithubMilestone{Number: issue.Milestone.IID, Title: issue.Milestone.Title}
+	}
+	return translated
+}
+
+// githubState returns Github's name of a GitLab issue state
+func githubState(state string) string {
+	if state == "opened" {
+		return trainingv1alpha1.StateOpen
+	}
+	return state
+}
+
+// CreateIssue opens a new issue in the project ownerRepo
+func (c *GitlabClient) CreateIssue(ownerRepo string, token string, issueData GithubSend) (GithubRecieve, int, error) {
+	data, code, err := c.issueData(ownerRepo, token, issueData)
+	if err != nil || code != 0 {
+		return GithubRecieve{}, code, err
+	}
+	return c.issueCall(ownerRepo, "POST", "/issues", data, token)
+}
+
+// GetIssue fetches issue number of the project ownerRepo
+func (c *GitlabClient) GetIssue(ownerRepo string, number int, token string) (GithubRecieve, int, error) {
+	return c.issueCall(ownerRepo, "GET", "/issues/"+strconv.Itoa(number), nil, token)
+}
+
+// UpdateIssue edits the fields set in issueData of issue number
+func (c *GitlabClient) UpdateIssue(ownerRepo string, number int, token string, issueData GithubSend) (GithubRecieve, int, error) {
+	data, code, err := c.issueData(ownerRepo, token, issueData)
+	if err != nil || code != 0 {
+		return GithubRecieve{}, code, err
+	}
+	return c.issueCall(ownerRepo, "PUT", "/issues/"+strconv.Itoa(number), data, token)
+}
+
+// CloseIssue changes the state of issue number into closed
+func (c *GitlabClient) CloseIssue(ownerRepo string, number int, token string) (GithubRecieve, int, error) {
+	return c.issueCall(ownerRepo, "PUT", "/issues/"+strconv.Itoa(number), gitlabIssueData{StateEvent: "close"}, token)
+}
+
+// LockIssue locks the discussion of issue number, GitLab's 200 OK is returned as Github's 204 No Content
+func (c *GitlabClient) LockIssue(ownerRepo string, number int, token string) (int, error) {
+	resp, _, err := c.call("PUT", c.projectPath(ownerRepo)+"/issues/"+strconv.Itoa(number), gitlabIssueData{DiscussionLocked: true}, token)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode == Ok_Code {
+		return No_Content_Code, nil
+	}
+	return resp.StatusCode, nil
+}
+
+// CreateComment posts a note with body on issue number
+func (c *GitlabClient) CreateComment(ownerRepo string, number int, token string, body string) (GithubComment, int, error) {
+	return c.noteCall(ownerRepo, "POST", "/issues/"+strconv.Itoa(number)+"/notes", gitlabNote{Body: body}, token)
+}
+
+// ListComments returns one page of the notes of issue number, oldest first, query sets page and per_page - https://docs.gitlab.com/ee/api/notes.html#list-project-issue-notes
+func (c *GitlabClient) ListComments(ownerRepo string, number int, query url.Values, token string) ([]GithubComment, int, error) {
+	var notes []gitlabNote
+	query = copyQuery(query)
+	query.Set("sort", "asc")
+	query.Set("order_by", "created_at")
+	resp, body, err := c.call("GET", c.projectPath(ownerRepo)+"/issues/"+strconv.Itoa(number)+"/notes?"+query.Encode(), nil, token)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode == Ok_Code {
+		if err := json.Unmarshal(body, &notes); err != nil {
+			return nil, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+	}
+	comments := make([]GithubComment, 0, len(notes))
+	for _, note := range notes {
+		comments = append(comments, GithubComment{ID: note.ID, Body: note.Body})
+	}
+	return comments, resp.StatusCode, nil
+}
+
+// UpdateComment replaces the body of note id of issue number
+func (c *GitlabClient) UpdateComment(ownerRepo string, number int, id int64, token string, body string) (GithubComment, int, error) {
+	return c.noteCall(ownerRepo, "PUT", "/issues/"+strconv.Itoa(number)+"/notes/"+strconv.FormatInt(id, 10), gitlabNote{Body: body}, token)
+}
+
+// DeleteComment deletes note id of issue number, GitLab responds 204 No Content
+func (c *GitlabClient) DeleteComment(ownerRepo string, number int, id int64, token string) (int, error) {
+	resp, _, err := c.call("DELETE", c.projectPath(ownerRepo)+"/issues/"+strconv.Itoa(number)+"/notes/"+strconv.FormatInt(id, 10), nil, token)
+	if err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+// SearchIssuesByTitle returns the issues of the project ownerRepo whose title contains title
+func (c *GitlabClient) SearchIssuesByTitle(ownerRepo string, title string, token string) ([]GithubRecieve, int, error) {
+	return c.listIssues(ownerRepo, url.Values{"search": {title}, "in": {"title"}, "per_page": {strconv.Itoa(PerPage)}}, token)
+}
+
+// ListIssues returns one page of the project's issues filtered by query, in Github's terms (state, labels, since, page, per_page...)
+// - https://docs.gitlab.com/ee/api/issues.html#list-project-issues
+func (c *GitlabClient) ListIssues(ownerRepo string, query url.Values, token string) ([]GithubRecieve, int, error) {
+	query = copyQuery(query)
+	switch query.Get("state") {
+	case "all":
+		query.Del("state") // GitLab lists the issues of every state by default
+	case trainingv1alpha1.StateOpen:
+		query.Set("state", "opened")
+	}
+	if since := query.Get("since"); since != "" {
+		query.Del("since")
+		query.Set("updated_after", since)
+	}
+	return c.listIssues(ownerRepo, query, token)
+}
+
+// listIssues returns one page of the project's issues filtered by query, in GitLab's terms
+func (c *GitlabClient) listIssues(ownerRepo string, query url.Values, token string) ([]GithubRecieve, int, error) {
+	var issues []gitlabIssue
+	resp, body, err := c.call("GET", c.projectPath(ownerRepo)+"/issues?"+query.Encode(), nil, token)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode == Ok_Code {
+		if err := json.Unmarshal(body, &issues); err != nil {
+			return nil, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+	}
+	translated := make([]GithubRecieve, 0, len(issues))
+	for _, issue := range issues {
+		translated = append(translated, issue.githubIssue())
+	}
+	return translated, resp.StatusCode, nil
+}
+
+// issueData translates issueData into GitLab's fields, looking the IDs of the assignees and the milestone up.
+// A non-zero code is the failed response of a lookup - 422, as Github's, when an assignee or the milestone doesn't exist
+func (c *GitlabClient) issueData(ownerRepo string, token string, issueData GithubSend) (gitlabIssueData, int, error) {
+	data := gitlabIssueData{
+		Title:       issueData.Title,
+		Description: issueData.Body,
+	}
+	if issueData.Labels != nil {
+		labels := strings.Join(issueData.Labels, ",")
+		data.Labels = &labels
+	}
+	switch issueData.State {
+	case trainingv1alpha1.StateClosed:
+		data.StateEvent = "close"
+	case trainingv1alpha1.StateOpen:
+		data.StateEvent = "reopen"
+	}
+	for _, login := range issueData.Assignees {
+		var users []gitlabUser
+		resp, body, err := c.call("GET", "/users?"+url.Values{"username": {login}}.Encode(), nil, token)
+		if err != nil {
+			return data, 0, err
+		}
+		if resp.StatusCode != Ok_Code {
+			return data, resp.StatusCode, nil
+		}
+		if err := json.Unmarshal(body, &users); err != nil {
+			return data, 0, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+		if len(users) == 0 {
+			return data, 422, nil
+		}
+		data.AssigneeIDs = append(data.AssigneeIDs, users[0].ID)
+	}
+	if issueData.Milestone != nil {
+		var milestones []gitlabMilestone
+		query := url.Values{"iids[]": {strconv.Itoa(*issueData.Milestone)}}
+		resp, body, err := c.call("GET", c.projectPath(ownerRepo)+"/milestones?"+query.Encode(), nil, token)
+		if err != nil {
+			return data, 0, err
+		}
+		if resp.StatusCode != Ok_Code {
+			return data, resp.StatusCode, nil
+		}
+		if err := json.Unmarshal(body, &milestones); err != nil {
+			return data, 0, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+		if len(milestones) == 0 {
+			return data, 422, nil
+		}
+		data.MilestoneID = milestones[0].ID
+	}
+	return data, 0, nil
+}
+
+// issueCall makes the call on path under the project and decodes the issue of a successful response
+func (c *GitlabClient) issueCall(ownerRepo string, method string, path string, payload interface{}, token string) (GithubRecieve, int, error) {
+	var issue gitlabIssue
+	resp, body, err := c.call(method, c.projectPath(ownerRepo)+path, payload, token)
+	if err != nil {
+		return GithubRecieve{}, 0, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := json.Unmarshal(body, &issue); err != nil {
+			return GithubRecieve{}, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+	}
+	return issue.githubIssue(), resp.StatusCode, nil
+}
+
+// noteCall makes the call on path under the project and decodes the note of a successful response
+func (c *GitlabClient) noteCall(ownerRepo string, method string, path string, payload interface{}, token string) (GithubComment, int, error) {
+	var note gitlabNote
+	resp, body, err := c.call(method, c.projectPath(ownerRepo)+path, payload, token)
+	if err != nil {
+		return GithubComment{}, 0, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := json.Unmarshal(body, &note); err != nil {
+			return GithubComment{}, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+	}
+	return GithubComment{ID: note.ID, Body: note.Body}, resp.StatusCode, nil
+}
+
+// projectPath returns the API path of the project ownerRepo, which is identified by its URL-encoded path
+func (c *GitlabClient) projectPath(ownerRepo string) string {
+	return "/projects/" + url.PathEscape(ownerRepo)
+}
+
+// call sends payload (if it isn't nil) as JSON to path under the client's BaseURL and reads the whole response,
+// authenticated with GitLab's PRIVATE-TOKEN header. The token is redacted from its error
+func (c *GitlabClient) call(method string, path string, payload interface{}, token string) (*http.Response, []byte, error) {
+	resp, body, err := sendJSON(c.HTTPClient, c.BaseURL, method, path, payload, http.Header{"Private-Token": {token}})
+	return resp, body, RedactError(err, token)
+}
+
+// sendJSON sends payload (if it isn't nil) as JSON to path under baseURL with the extra header set, and reads the whole response
+func sendJSON(httpClient *http.Client, baseURL string, method string, path string, payload interface{}, header http.Header) (*http.Response, []byte, error) {
+	reqBody := bytes.NewReader(nil)
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		reqBody = bytes.NewReader(jsonData)
+	}
+	req, err := http.NewRequest(method, baseURL+path, reqBody)
+	if err != nil {
+		return nil, nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		observeCall(baseURL, method, 0, start)
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	observeCall(baseURL, method, resp.StatusCode, start)
+	return resp, body, err
+}
+
+// copyQuery returns a copy of query which can be changed without changing query
+func copyQuery(query url.Values) url.Values {
+	copied := make(url.Values, len(query))
+	for key, values := range query {
+		copied[key] = append([]string(nil), values...)
+	}
+	return copied
+}
@@ -0,0 +1,307 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+)
+
+var _ = Describe("GitLab provider", func() {
+	const (
+		RepoName    = "team/project"
+		ProjectPath = GitlabAPIPath + "/projects/team%2Fproject"
+	)
+	var (
+		server  *httptest.Server
+		mu      sync.Mutex
+		issues  map[int]map[string]interface{} // the project's issues by IID, as GitLab returns them
+		notes   map[int64]gitlabNote
+		nextID  int64
+		tokens  []string
+		githubi trainingv1alpha1.GithubIssue
+	)
+
+	// a stand-in for a GitLab server with the users alice (ID 11) and bob (ID 12) and the milestone %3 (ID 103)
+	BeforeEach(func() {
+		issues, notes, nextID, tokens = map[int]map[string]interface{}{}, map[int64]gitlabNote{}, 500, nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			tokens = append(tokens, req.Header.Get("PRIVATE-TOKEN"))
+			var data map[string]interface{}
+			_ = json.NewDecoder(req.Body).Decode(&data)
+			path := req.URL.EscapedPath()
+			switch {
+			case path == GitlabAPIPath+"/users":
+				users := map[string]int{"alice": 11, "bob": 12}
+				found := []gitlabUser{}
+				if id, ok := users[req.URL.Query().Get("username")]; ok {
+					found = append(found, gitlabUser{ID: id, Username: req.URL.Query().Get("username")})
+				}
+				_ = json.NewEncoder(w).Encode(found)
+			case path == ProjectPath+"/milestones":
+				found := []gitlabMilestone{}
+				if req.URL.Query().Get("iids[]") == "3" {
+					found = append(found, gitlabMilestone{ID: 103, IID: 3, Title: "v1"})
+				}
+				_ = json.NewEncoder(w).Encode(found)
+			case path == ProjectPath+"/issues" && req.Method == "GET":
+				listed := []map[string]interface{}{}
+				for iid := 1; iid <= len(issues); iid++ {
+					title, _ := issues[iid]["title"].(string)
+					if search := req.URL.Query().Get("search"); search == "" || strings.Contains(title, search) {
+						listed = append(listed, issues[iid])
+					}
+				}
+				_ = json.NewEncoder(w).Encode(listed)
+			case path == ProjectPath+"/issues" && req.Method == "POST":
+				iid := len(issues) + 1
+				issues[iid] = map[string]interface{}{"iid": iid, "state": "opened", "labels": []string{}, "assignees": []interface{}{},
+					"web_url": "https://gitlab.example.com/" + RepoName + "/-/issues/" + strconv.Itoa(iid)}
+				applyGitlabData(issues[iid], data)
+				w.WriteHeader(http.StatusCreated)
+				_ = json.NewEncoder(w).Encode(issues[iid])
+			case strings.HasPrefix(path, ProjectPath+"/issues/"):
+				parts := strings.Split(strings.TrimPrefix(path, ProjectPath+"/issues/"), "/")
+				iid, _ := strconv.Atoi(parts[0])
+				issue, ok := issues[iid]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				switch {
+				case len(parts) == 1 && req.Method == "PUT":
+					applyGitlabData(issue, data)
+					_ = json.NewEncoder(w).Encode(issue)
+				case len(parts) == 1:
+					_ = json.NewEncoder(w).Encode(issue)
+				case len(parts) == 2 && req.Method == "POST":
+					note := gitlabNote{ID: nextID, Body: data["body"].(string)}
+					notes[nextID] = note
+					nextID++
+					w.WriteHeader(http.StatusCreated)
+					_ = json.NewEncoder(w).Encode(note)
+				case len(parts) == 2:
+					listed := []gitlabNote{}
+					for id := int64(500); id < nextID; id++ {
+						if note, ok := notes[id]; ok {
+							listed = append(listed, note)
+						}
+					}
+					_ = json.NewEncoder(w).Encode(listed)
+				default:
+					id, _ := strconv.ParseInt(parts[2], 10, 64)
+					note, ok := notes[id]
+					if !ok {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					if req.Method == "DELETE" {
+						delete(notes, id)
+						w.WriteHeader(http.StatusNoContent)
+						return
+					}
+					note.Body = data["body"].(string)
+					notes[id] = note
+					_ = json.NewEncoder(w).Encode(note)
+				}
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		githubi = trainingv1alpha1.GithubIssue{Spec: trainingv1alpha1.GithubIssueSpec{
+			Repo:        "https://gitlab.example.com/" + RepoName,
+			Provider:    trainingv1alpha1.ProviderGitlab,
+			Title:       "GitLab issue",
+			Description: "Hi from testing K8s",
+			Labels:      []string{"bug", "ops"},
+			Assignees:   []string{"alice"},
+		}}
+		githubi.UID = "4a1f0a1e-7a52-4d0c-8f3e-2a7f6f1b9c11"
+		githubi.Finalizers = []string{FinalizerName}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	client := func() *GitlabClient {
+		return NewGitlabClient(server.URL+GitlabAPIPath, server.Client())
+	}
+
+	It("should create an issue with its labels and assignees and record its IID", func() {
+		githubi, err, _ := GetIssue(client(), githubi, RepoName, "glpat-token", "POST")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(githubi.Status.Number).To(Equal(1))
+		Expect(githubi.Status.State).To(Equal(trainingv1alpha1.StateOpen))
+		Expect(githubi.Status.Labels).To(ConsistOf("bug", "ops"))
+		Expect(githubi.Status.Assignees).To(ConsistOf("alice"))
+		Expect(issues[1]["description"]).To(Equal(IssueBody(githubi)))
+		Expect(tokens).To(ContainElement("glpat-token"))
+		Expect(tokens).NotTo(ContainElement(""))
+	})
+
+	It("should remove every label once spec.labels is an empty list", func() {
+		githubi, err, _ := GetIssue(client(), githubi, RepoName, "glpat-token", "POST")
+		Expect(err).NotTo(HaveOccurred())
+		githubi.Spec.Labels = []string{}
+		githubi, err, updated := GetIssue(client(), githubi, RepoName, "glpat-token", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated).To(BeTrue())
+		Expect(githubi.Status.Labels).To(BeEmpty())
+		Expect(issues[1]["labels"]).To(BeEmpty())
+	})
+
+	It("should identify a project of nested groups by its whole URL-encoded path", func() {
+		var paths []string
+		server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			paths = append(paths, req.URL.EscapedPath())
+			_, _ = w.Write([]byte(`{"iid": 4, "state": "opened"}`))
+		})
+		issue, code, err := client().GetIssue("group/subgroup/project", 4, "glpat-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(issue.Number).To(Equal(4))
+		Expect(paths).To(Equal([]string{GitlabAPIPath + "/projects/group%2Fsubgroup%2Fproject/issues/4"}))
+	})
+
+	It("should update a drifted issue and close it with a state event", func() {
+		githubi, err, _ := GetIssue(client(), githubi, RepoName, "glpat-token", "POST")
+		Expect(err).NotTo(HaveOccurred())
+		githubi.Spec.Title = "GitLab issue, renamed"
+		milestone := 3
+		githubi.Spec.Milestone = &milestone
+		githubi.Spec.State = trainingv1alpha1.StateClosed
+		githubi, err, updated := GetIssue(client(), githubi, RepoName, "glpat-token", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated).To(BeTrue())
+		Expect(githubi.Status.Title).To(Equal("GitLab issue, renamed"))
+		Expect(githubi.Status.State).To(Equal(trainingv1alpha1.StateClosed))
+		Expect(githubi.Status.Milestone).To(Equal(3))
+
+		_, err, updated = GetIssue(client(), githubi, RepoName, "glpat-token", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated).To(BeFalse())
+	})
+
+	It("should reject an unknown assignee as Github does", func() {
+		githubi.Spec.Assignees = []string{"mallory"}
+		_, err, _ := GetIssue(client(), githubi, RepoName, "glpat-token", "POST")
+		var invalid *ValidationError
+		Expect(errors.As(err, &invalid)).To(BeTrue())
+		Expect(invalid.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(issues).To(BeEmpty())
+	})
+
+	It("should find an issue by its marker and adopt one by its title", func() {
+		githubi.CreationTimestamp.Time = time.Now()
+		created, err, _ := GetIssue(client(), githubi, RepoName, "glpat-token", "POST")
+		Expect(err).NotTo(HaveOccurred())
+		_, number, err := FindIssueToAdopt(client(), githubi, RepoName, "glpat-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(number).To(Equal(created.Status.Number))
+
+		other := githubi
+		other.UID = "another-uid"
+		other.Spec.AdoptByTitle = true
+		_, number, err = FindIssueToAdopt(client(), other, RepoName, "glpat-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(number).To(Equal(created.Status.Number))
+	})
+
+	It("should sync comments as notes and lock the issue on deletion", func() {
+		githubi, err, _ := GetIssue(client(), githubi, RepoName, "glpat-token", "POST")
+		Expect(err).NotTo(HaveOccurred())
+		githubi.Spec.Comments = []trainingv1alpha1.IssueComment{{Key: "deploy", Body: "deploy of v1.4 finished"}}
+		githubi, _, err = SyncComments(client(), githubi, RepoName, "glpat-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(githubi.Status.Comments).To(HaveLen(1))
+		Expect(githubi.Status.Comments[0].ID).To(Equal(int64(500)))
+
+		githubi.Spec.Comments[0].Body = "deploy of v1.5 finished"
+		githubi, changed, err := SyncComments(client(), githubi, RepoName, "glpat-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(notes[500].Body).To(ContainSubstring("deploy of v1.5 finished"))
+
+		githubi.Spec.DeletionPolicy = trainingv1alpha1.DeletionPolicyLock
+		githubi, err = DeleteIssue(client(), githubi, RepoName, "glpat-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(githubi.Finalizers).To(BeEmpty())
+		Expect(issues[1]["state"]).To(Equal("closed"))
+		Expect(issues[1]["discussion_locked"]).To(BeTrue())
+	})
+
+	It("should take the provider from spec.provider, the hosts configuration or the host's name", func() {
+		hosts, err := NewHosts(HostsConfig{Hosts: []HostConfig{{Host: "code.example.com", Provider: trainingv1alpha1.ProviderGitlab}}}, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		host, _ := hosts.Lookup("code.example.com")
+		Expect(host.Client.(*GitlabClient).BaseURL).To(Equal("https://code.example.com" + GitlabAPIPath))
+		Expect(host.AppTokens).To(BeNil())
+
+		Expect(ProviderFor("", "code.example.com", hosts)).To(Equal(trainingv1alpha1.ProviderGitlab))
+		Expect(ProviderFor("", "gitlab.com", nil)).To(Equal(trainingv1alpha1.ProviderGitlab))
+		Expect(ProviderFor("", "gitlab.example.com:8443", nil)).To(Equal(trainingv1alpha1.ProviderGitlab))
+		Expect(ProviderFor("", "github.com", nil)).To(Equal(trainingv1alpha1.ProviderGithub))
+		Expect(ProviderFor("", "mygitlab.example.com", nil)).To(Equal(trainingv1alpha1.ProviderGithub))
+		Expect(ProviderFor("", "forgejo.site-a.internal", nil)).To(Equal(trainingv1alpha1.ProviderGitea))
+		Expect(ProviderFor(trainingv1alpha1.ProviderGitlab, "git.example.com", nil)).To(Equal(trainingv1alpha1.ProviderGitlab))
+	})
+})
+
+// applyGitlabData applies the fields of an issue sent to the stand-in GitLab server, as GitLab does
+func applyGitlabData(issue map[string]interface{}, data map[string]interface{}) {
+	for _, key := range []string{"title", "description", "discussion_locked"} {
+		if value, ok := data[key]; ok {
+			issue[key] = value
+		}
+	}
+	if labels, ok := data["labels"].(string); ok {
+		issue["labels"] = []string{}
+		if labels != "" {
+			issue["labels"] = strings.Split(labels, ",")
+		}
+	}
+	if ids, ok := data["assignee_ids"].([]interface{}); ok {
+		usernames := map[float64]string{11: "alice", 12: "bob"}
+		var assignees []gitlabUser
+		for _, id := range ids {
+			assignees = append(assignees, gitlabUser{ID: int(id.(float64)), Username: usernames[id.(float64)]})
+		}
+		issue["assignees"] = assignees
+	}
+	if id, ok := data["milestone_id"].(float64); ok && id == 103 {
+		issue["milestone"] = gitlabMilestone{ID: 103, IID: 3, Title: "v1"}
+	}
+	switch data["state_event"] {
+	case "close":
+		issue["state"] = "closed"
+	case "reopen":
+		issue["state"] = "opened"
+	}
+}
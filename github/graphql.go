@@ -0,0 +1,264 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// IssueBatches is the GraphQL resync mode of a RestClient - instead of a REST GET per issue, the managed issues of a repo,
+// the ones GetIssue was asked for lately, are fetched together with one GraphQL query of PerPage issues a page,
+// at most once a period - https://docs.github.com/en/graphql.
+// The reconciles of the repo's other issues read them from the batch until it is a period old.
+// A repo has a batch per credential, so an issue is only served to the reconciles using the credential it was fetched with.
+// An issue missing from the batch, or asked for when a batch failed, is fetched with REST as before and the batch is fetched again
+// on the next call. A batch rejected by the GraphQL rate limit fails the call with a RateLimitError instead, as a REST call would
+type IssueBatches struct {
+	// Period is how long a fetched batch is served before the repo's issues are fetched again
+	Period time.Duration
+	// Now returns the current time, it is replaced in tests
+	Now func() time.Time
+
+	mu    sync.Mutex
+	repos map[string]map[string]*repoBatch // ownerRepo -> the fingerprint of a credential -> its batch
+}
+
+// repoBatch is the last batch of a repo, its lock is held while the batch is fetched so the repo's reconciles wait for it
+type repoBatch struct {
+	mu      sync.Mutex
+	fetched time.Time
+	tracked map[int]time.Time // the managed issues -> when GetIssue was last asked for them
+	issues  map[int]batchedIssue
+}
+
+// batchedIssue is an issue of a batch, served is the updatedAt of the version last returned by GetIssue
+type batchedIssue struct {
+	issue     GithubRecieve
+	updatedAt string
+	served    string
+}
+
+// NewIssueBatches returns an empty IssueBatches fetching the issues of a repo at most once a period
+func NewIssueBatches(period time.Duration) *IssueBatches {
+	return &IssueBatches{Period: period, Now: time.Now, repos: map[string]map[string]*repoBatch{}}
+}
+
+// repo returns the batch of ownerRepo fetched with token
+func (b *IssueBatches) repo(ownerRepo string, token string) *repoBatch {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	batches, ok := b.repos[ownerRepo]
+	if !ok {
+		batches = map[string]*repoBatch{}
+		b.repos[ownerRepo] = batches
+	}
+	key := fingerprint(token)
+	batch, ok := batches[key]
+	if !ok {
+		batch = &repoBatch{tracked: map[int]time.Time{}, issues: map[int]batchedIssue{}}
+		batches[key] = batch
+	}
+	return batch
+}
+
+// Forget drops issue number of ownerRepo from the repo's batches, so the next GetIssue fetches it with REST -
+// once the operator changes it, or a webhook delivers a change made on Github
+func (b *IssueBatches) Forget(ownerRepo string, number int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	batches := make([]*repoBatch, 0, len(b.repos[ownerRepo]))
+	for _, batch := range b.repos[ownerRepo] {
+		batches = append(batches, batch)
+	}
+	b.mu.Unlock()
+	for _, batch := range batches {
+		batch.mu.Lock()
+		delete(batch.issues, number)
+		batch.mu.Unlock()
+	}
+}
+
+// batchedIssue returns issue number of ownerRepo from the repo's batch, fetching the batch again when it is a period old.
+// An unchanged issue, whose updatedAt was returned before, comes with Not_Modified_Code as a conditional GET does.
+// False means the issue should be fetched with REST, a RateLimitError that it shouldn't be fetched at all for now
+func (c *RestClient) batchedIssue(ownerRepo string, number int, token string) (GithubRecieve, int, bool, error) {
+	batch := c.Batches.repo(ownerRepo, token)
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	now := c.Batches.Now()
+	batch.tracked[number] = now
+	if now.Sub(batch.fetched) >= c.Batches.Period {
+		numbers := make([]int, 0, len(batch.tracked))
+		for tracked, requested := range batch.tracked {
+			if now.Sub(requested) < 3*c.Batches.Period { // an issue no reconcile asked for lately isn't managed anymore
+				numbers = append(numbers, tracked)
+			} else {
+				delete(batch.tracked, tracked)
+			}
+		}
+		sort.Ints(numbers)
+		issues, err := c.fetchBatch(ownerRepo, numbers, token)
+		var limited *RateLimitError
+		if errors.As(err, &limited) {
+			return GithubRecieve{}, 0, false, err
+		}
+		if err != nil { // the issue is fetched with REST, and the batch again on the next call
+			graphQLLog.Error(err, "Fetching a batch of issues, falling back to REST", "repo", ownerRepo)
+			batch.issues = map[int]batchedIssue{}
+			return GithubRecieve{}, 0, false, nil
+		}
+		for number, issue := range issues {
+			if previous, ok := batch.issues[number]; ok && previous.updatedAt == issue.updatedAt {
+				issue.served = previous.served
+			}
+			issues[number] = issue
+		}
+		batch.issues, batch.fetched = issues, now
+	}
+	cached, ok := batch.issues[number]
+	if !ok {
+		return GithubRecieve{}, 0, false, nil
+	}
+	if cached.served == cached.updatedAt {
+		return cached.issue, Not_Modified_Code, true, nil
+	}
+	cached.served = cached.updatedAt
+	batch.issues[number] = cached
+	return cached.issue, Ok_Code, true, nil
+}
+
+// graphQLRateLimited is the type of the error of a GraphQL query rejected by the rate limit, which comes with a 200
+const graphQLRateLimited = "RATE_LIMITED"
+
+// graphQLLog logs the failed batches, whose issues are fetched with REST instead
+var graphQLLog = logf.Log.WithName("graphql")
+
+// graphQLIssue is an issue as the GraphQL API returns it
+type graphQLIssue struct {
+	Number      int    `json:"number"`
+	Title       string `json:"title"`
+	Body        string `json:"body"`
+	State       string `json:"state"`       // OPEN or CLOSED
+	StateReason string `json:"stateReason"` // COMPLETED, NOT_PLANNED or REOPENED
+	UpdatedAt   string `json:"updatedAt"`
+	URL         string `json:"url"`
+	Labels      struct {
+		Nodes []GithubLabel `json:"nodes"`
+	} `json:"labels"`
+	Assignees struct {
+		Nodes []GithubUser `json:"nodes"`
+	} `json:"assignees"`
+	Milestone *GithubMilestone `json:"milestone"`
+}
+
+// graphQLIssueFields are the fields of an issue a batch fetches, the ones compared with the spec
+const graphQLIssueFields = `number title body state stateReason updatedAt url
+labels(first: 100) { nodes { name } } assignees(first: 100) { nodes { login } } milestone { number title }`
+
+// fetchBatch fetches the issues numbers of ownerRepo with one GraphQL query of PerPage issues a page.
+// An issue which doesn't exist, or isn't an issue anymore, is left out of the batch
+func (c *RestClient) fetchBatch(ownerRepo string, numbers []int, token string) (map[int]batchedIssue, error) {
+	owner, name := ownerRepo, ""
+	if slash := strings.Index(ownerRepo, "/"); slash >= 0 {
+		owner, name = ownerRepo[:slash], ownerRepo[slash+1:]
+	}
+	issues := make(map[int]batchedIssue, len(numbers))
+	for start := 0; start < len(numbers); start += PerPage {
+		end := start + PerPage
+		if end > len(numbers) {
+			end = len(numbers)
+		}
+		var query strings.Builder
+		query.WriteString("query($owner: String!, $name: String!) { repository(owner: $owner, name: $name) {")
+		for _, number := range numbers[start:end] {
+			fmt.Fprintf(&query, " i%d: issue(number: %d) { ...issueFields }", number, number)
+		}
+		query.WriteString(" } }\nfragment issueFields on Issue { " + graphQLIssueFields + " }")
+		var result struct {
+			Data struct {
+				Repository map[string]*graphQLIssue `json:"repository"`
+			} `json:"data"`
+			Errors []struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		payload := map[string]interface{}{"query": query.String(), "variables": map[string]string{"owner": owner, "name": name}}
+		resp, body, err := c.request("POST", c.GraphQLURL, payload, token, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != Ok_Code {
+			return nil, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("the GraphQL query failed"))
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return nil, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+		for _, queryError := range result.Errors {
+			if queryError.Type == graphQLRateLimited {
+				return nil, c.RateLimits.ObserveRateLimited(token, resp)
+			}
+		}
+		if result.Data.Repository == nil {
+			message := "no repository"
+			if len(result.Errors) > 0 {
+				message = result.Errors[0].Message
+			}
+			return nil, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("the GraphQL query failed: %s", message))
+		}
+		for _, issue := range result.Data.Repository { // a missing issue is null, with a NOT_FOUND error
+			if issue != nil {
+				issues[issue.Number] = batchedIssue{issue: issue.githubIssue(), updatedAt: issue.UpdatedAt}
+			}
+		}
+	}
+	return issues, nil
+}
+
+// githubIssue translates issue into the issue the REST API returns
+func (issue graphQLIssue) githubIssue() GithubRecieve {
+	return GithubRecieve{
+		Repo:        issue.URL,
+		Title:       issue.Title,
+		Description: issue.Body,
+		State:       strings.ToLower(issue.State),
+		StateReason: strings.ToLower(issue.StateReason),
+		Number:      issue.Number,
+		Labels:      issue.Labels.Nodes,
+		Assignees:   issue.Assignees.Nodes,
+		Milestone:   issue.Milestone,
+	}
+}
+
+// graphQLURL returns the GraphQL endpoint of the REST API root baseURL - https://<host>/api/graphql for Github Enterprise's
+// https://<host>/api/v3, and <baseURL>/graphql otherwise, e.g. https://api.github.com/graphql
+func graphQLURL(baseURL string) string {
+	if strings.HasSuffix(baseURL, EnterpriseAPIPath) {
+		return strings.TrimSuffix(baseURL, EnterpriseAPIPath) + GraphQLEnterprisePath
+	}
+	return baseURL + GraphQLPath
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+)
+
+var _ = Describe("Github GraphQL resyncs", func() {
+	const RepoName = "razo7/githubissues-operator"
+	var (
+		server      *httptest.Server
+		client      *RestClient
+		now         time.Time
+		issues      map[int]map[string]interface{} // the repo's issues as GraphQL returns them
+		queried     [][]string                     // the issue numbers of every GraphQL query
+		restGets    []string
+		failQuery   bool
+		rateLimited bool
+	)
+
+	BeforeEach(func() {
+		now, queried, restGets, failQuery, rateLimited = time.Date(2021, 7, 1, 10, 0, 0, 0, time.UTC), nil, nil, false, false
+		issues = map[int]map[string]interface{}{}
+		for number := 1; number <= 3; number++ {
+			issues[number] = map[string]interface{}{
+				"number": number, "title": "issue " + strconv.Itoa(number), "body": "d", "state": "OPEN", "stateReason": nil,
+				"updatedAt": "2021-07-01T09:00:00Z", "labels": map[string]interface{}{"nodes": []GithubLabel{{Name: "bug"}}},
+				"assignees": map[string]interface{}{"nodes": []GithubUser{}}, "milestone": nil,
+			}
+		}
+		alias := regexp.MustCompile(`i(\d+): issue\(number: \d+\)`)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch {
+			case req.URL.Path == GraphQLPath:
+				var payload struct {
+					Query     string            `json:"query"`
+					Variables map[string]string `json:"variables"`
+				}
+				_ = json.NewDecoder(req.Body).Decode(&payload)
+				Expect(payload.Variables).To(Equal(map[string]string{"owner": "razo7", "name": "githubissues-operator"}))
+				if rateLimited { // as Github rejects a query once the GraphQL budget is exhausted
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []map[string]string{{"type": "RATE_LIMITED", "message": "API rate limit exceeded"}}})
+					return
+				}
+				if failQuery {
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []map[string]string{{"message": "Field 'stateReason' doesn't exist on type 'Issue'"}}})
+					return
+				}
+				repository := map[string]interface{}{}
+				var numbers []string
+				for _, match := range alias.FindAllStringSubmatch(payload.Query, -1) {
+					numbers = append(numbers, match[1])
+					number, _ := strconv.Atoi(match[1])
+					if issue, ok := issues[number]; ok {
+						repository["i"+match[1]] = issue
+					} else {
+						repository["i"+match[1]] = nil
+					}
+				}
+				queried = append(queried, numbers)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"repository": repository}})
+			case req.Method == "GET":
+				restGets = append(restGets, req.URL.Path)
+				number, _ := strconv.Atoi(req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:])
+				_ = json.NewEncoder(w).Encode(GithubRecieve{Number: number, Title: "issue " + strconv.Itoa(number), Description: "d", State: "open"})
+			case req.Method == "PATCH":
+				var data GithubSend
+				_ = json.NewDecoder(req.Body).Decode(&data)
+				_ = json.NewEncoder(w).Encode(GithubRecieve{Number: 1, Title: data.Title, Description: "d", State: "open"})
+			}
+		}))
+		client = NewClient(server.URL, server.Client())
+		client.Batches = NewIssueBatches(time.Minute)
+		client.Batches.Now = func() time.Time { return now }
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should fetch the managed issues of a repo in one query and serve them from the batch", func() {
+		issue, code, err := client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(Ok_Code))
+		Expect(issue.Title).To(Equal("issue 1"))
+		Expect(issue.State).To(Equal(trainingv1alpha1.StateOpen))
+		Expect(issue.LabelNames()).To(ConsistOf("bug"))
+		// issue 2 isn't in the first batch, it is fetched with REST and joins the next one
+		_, code, err = client.GetIssue(RepoName, 2, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(Ok_Code))
+		Expect(restGets).To(Equal([]string{"/repos/" + RepoName + "/issues/2"}))
+
+		now = now.Add(time.Minute)
+		issues[2]["title"], issues[2]["updatedAt"] = "renamed on Github", "2021-07-01T10:00:30Z"
+		_, code, err = client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(Not_Modified_Code)) // its updatedAt didn't change
+		issue, code, err = client.GetIssue(RepoName, 2, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(Ok_Code))
+		Expect(issue.Title).To(Equal("renamed on Github"))
+		Expect(queried).To(Equal([][]string{{"1"}, {"1", "2"}}))
+		Expect(restGets).To(HaveLen(1))
+	})
+
+	It("should fetch an issue the operator edited with REST", func() {
+		_, _, err := client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = client.UpdateIssue(RepoName, 1, "a-token", GithubSend{Title: "renamed"})
+		Expect(err).NotTo(HaveOccurred())
+		_, code, err := client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(Ok_Code))
+		Expect(restGets).To(Equal([]string{"/repos/" + RepoName + "/issues/1"}))
+		Expect(queried).To(HaveLen(1))
+	})
+
+	It("should fetch an issue with REST once a webhook delivers its change", func() {
+		_, _, err := client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		issues[1]["title"], issues[1]["updatedAt"] = "renamed on Github", "2021-07-01T10:00:10Z"
+		client.Batches.Forget(RepoName, 1)
+		_, code, err := client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(Ok_Code))
+		Expect(restGets).To(Equal([]string{"/repos/" + RepoName + "/issues/1"}))
+		Expect(queried).To(HaveLen(1))
+	})
+
+	It("should serve a batch only to the reconciles using the credential it was fetched with", func() {
+		_, _, err := client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		_, code, err := client.GetIssue(RepoName, 1, "another-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(Ok_Code))
+		Expect(queried).To(Equal([][]string{{"1"}, {"1"}}))
+	})
+
+	It("should fall back to REST when the query fails and query again on the next call", func() {
+		failQuery = true
+		_, code, err := client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(Ok_Code))
+		_, _, err = client.GetIssue(RepoName, 2, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restGets).To(HaveLen(2))
+
+		failQuery = false // within the same period
+		_, _, err = client.GetIssue(RepoName, 3, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(queried).To(Equal([][]string{{"1", "2", "3"}}))
+		Expect(restGets).To(HaveLen(2))
+	})
+
+	It("should fail with a RateLimitError instead of falling back to REST when the query is rate limited", func() {
+		rateLimited = true
+		_, _, err := client.GetIssue(RepoName, 1, "a-token")
+		var limited *RateLimitError
+		Expect(errors.As(err, &limited)).To(BeTrue())
+		Expect(restGets).To(BeEmpty())
+
+		By("pausing the credential until the limit resets")
+		_, _, err = client.GetIssue(RepoName, 2, "a-token")
+		Expect(errors.As(err, &limited)).To(BeTrue())
+		Expect(restGets).To(BeEmpty())
+	})
+
+	It("should skip the comparison of an unchanged issue and spec in a reconcile", func() {
+		githubi := trainingv1alpha1.GithubIssue{
+			Spec:   trainingv1alpha1.GithubIssueSpec{Title: "issue 1", Description: "d", Labels: []string{"bug"}},
+			Status: trainingv1alpha1.GithubIssueStatus{Number: 1, State: "open"},
+		}
+		githubi, err, updated := GetIssue(client, githubi, RepoName, "a-token", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated).To(BeFalse())
+		Expect(githubi.Status.Labels).To(ConsistOf("bug"))
+		_, err, updated = GetIssue(client, githubi, RepoName, "a-token", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated).To(BeFalse())
+		Expect(restGets).To(BeEmpty())
+	})
+
+	It("should find the GraphQL endpoint of Github.com and Github Enterprise", func() {
+		Expect(NewClient("", nil).GraphQLURL).To(Equal("https://api.github.com/graphql"))
+		Expect(NewClient("https://github.example.com"+EnterpriseAPIPath, nil).GraphQLURL).To(Equal("https://github.example.com/api/graphql"))
+	})
+})
@@ -0,0 +1,223 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+	"sigs.k8s.io/yaml"
+)
+
+// HostsConfig is the operator-level configuration of the Github Enterprise, GitLab, Gitea and Jira hosts, e.g.
+//
+//	hosts:
+//	- host: github.example.com
+//	  credentialsSecret: {namespace: githubissues-operator-system, name: ghe-token}
+//	  caBundle: |
+//	    -----BEGIN CERTIFICATE-----
+//	    ...
+//	- host: code.example.com
+//	  provider: gitlab
+//	  credentialsSecret: {namespace: githubissues-operator-system, name: gitlab-token}
+//	- host: git.site-a.internal
+//	  provider: gitea
+//	  credentialsSecret: {namespace: githubissues-operator-system, name: gitea-token}
+//	- host: example.atlassian.net
+//	  jira: {issueType: Bug, closeTransition: Resolve}
+//	  credentialsSecret: {namespace: githubissues-operator-system, name: jira-token}
+type HostsConfig struct {
+	Hosts []HostConfig `json:"hosts"`
+}
+
+// HostConfig is how the operator reaches one Github, GitLab, Gitea or Jira host
+type HostConfig struct {
+	// Host is the repo host, as in https://<host>/<owner>/<repo>
+	Host string `json:"host"`
+	// Provider is github, gitlab, gitea or jira, guessed from the host's name when it is empty
+	Provider string `json:"provider,omitempty"`
+	// APIURL is the REST API root of the host, https://<host>/api/v3 (https://<host>/api/v4 for GitLab,
+	// https://<host>/api/v1 for Gitea and https://<host>/rest/api/2 for Jira) when it is empty
+	APIURL string `json:"apiURL,omitempty"`
+	// CredentialsSecret holds the token, or the Github App ID and private key, of the GithubIssues of the host
+	// which have no spec.credentialsSecretRef
+	CredentialsSecret *HostSecretRef `json:"credentialsSecret,omitempty"`
+	// CABundle is the PEM bundle of the CAs the host's certificate is verified with, on top of the system's
+	CABundle string `json:"caBundle,omitempty"`
+	// Jira is the issue type and workflow transitions of a Jira host's projects
+	Jira *JiraConfig `json:"jira,omitempty"`
+}
+
+// JiraConfig is how the issues of a Jira host are created, opened and closed
+type JiraConfig struct {
+	// IssueType is the type of the created issues, JiraIssueType when it is empty
+	IssueType string `json:"issueType,omitempty"`
+	// OpenTransition is the name of the transition, or of its target status, which reopens an issue.
+	// JiraOpenTransition when it is empty
+	OpenTransition string `json:"openTransition,omitempty"`
+	// CloseTransition is the name of the transition, or of its target status, which closes an issue.
+	// JiraCloseTransition when it is empty
+	CloseTransition string `json:"closeTransition,omitempty"`
+}
+
+// HostSecretRef selects a key of a Secret in any namespace
+type HostSecretRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Key holds the token, DefaultSecretKey when it is empty
+	Key string `json:"key,omitempty"`
+}
+
+// LoadHostsConfig reads a HostsConfig from a YAML or JSON file
+func LoadHostsConfig(path string) (HostsConfig, error) {
+	var config HostsConfig
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return config, fmt.Errorf("%v: %s :%w", HOST_ERROR, path, err)
+	}
+	return config, nil
+}
+
+// Host is a configured host, with its own client and App token source so their rate limits and caches stay apart.
+// A GitLab, Gitea or Jira host has no App token source
+type Host struct {
+	Config    HostConfig
+	Client    Client
+	AppTokens *AppTokenSource
+}
+
+// Hosts routes the repos of each configured Github Enterprise, GitLab, Gitea or Jira host to its API endpoint
+type Hosts struct {
+	hosts map[string]*Host
+}
+
+// NewHosts builds a client for every host of config, its HTTP clients time out after timeout
+func NewHosts(config HostsConfig, timeout time.Duration) (*Hosts, error) {
+	hosts := &Hosts{hosts: map[string]*Host{}}
+	for _, hostConfig := range config.Hosts {
+		name := strings.ToLower(strings.TrimSpace(hostConfig.Host))
+		if name == "" {
+			return nil, fmt.Errorf("%v: a host has no name", HOST_ERROR)
+		}
+		if _, ok := hosts.hosts[name]; ok {
+			return nil, fmt.Errorf("%v: host %s is configured twice", HOST_ERROR, name)
+		}
+		if hostConfig.Provider == "" {
+			hostConfig.Provider = guessProvider(name)
+		}
+		httpClient, err := hostHTTPClient(hostConfig, timeout)
+		if err != nil {
+			return nil, err
+		}
+		switch hostConfig.Provider {
+		case trainingv1alpha1.ProviderGithub:
+			if hostConfig.APIURL == "" {
+				hostConfig.APIURL = "https://" + name + EnterpriseAPIPath
+			}
+			hosts.hosts[name] = &Host{
+				Config:    hostConfig,
+				Client:    NewClient(hostConfig.APIURL, httpClient),
+				AppTokens: NewAppTokenSource(hostConfig.APIURL, httpClient),
+			}
+		case trainingv1alpha1.ProviderGitlab:
+			if hostConfig.APIURL == "" {
+				hostConfig.APIURL = "https://" + name + GitlabAPIPath
+			}
+			hosts.hosts[name] = &Host{Config: hostConfig, Client: NewGitlabClient(hostConfig.APIURL, httpClient)}
+		case trainingv1alpha1.ProviderGitea:
+			if hostConfig.APIURL == "" {
+				hostConfig.APIURL = "https://" + name + GiteaAPIPath
+			}
+			hosts.hosts[name] = &Host{Config: hostConfig, Client: NewGiteaClient(hostConfig.APIURL, httpClient)}
+		case trainingv1alpha1.ProviderJira:
+			if hostConfig.APIURL == "" {
+				hostConfig.APIURL = "https://" + name + JiraAPIPath
+			}
+			var jiraConfig JiraConfig
+			if hostConfig.Jira != nil {
+				jiraConfig = *hostConfig.Jira
+			}
+			hosts.hosts[name] = &Host{Config: hostConfig, Client: NewJiraClient(hostConfig.APIURL, httpClient, jiraConfig)}
+		default:
+			return nil, fmt.Errorf("%v: host %s has an unknown provider %s", HOST_ERROR, name, hostConfig.Provider)
+		}
+	}
+	return hosts, nil
+}
+
+// EnableBatches makes the Github hosts fetch the issues of a repo in GraphQL batches, at most once a period, see IssueBatches
+func (h *Hosts) EnableBatches(period time.Duration) {
+	if h == nil {
+		return
+	}
+	for _, host := range h.hosts {
+		if client, ok := host.Client.(*RestClient); ok {
+			client.Batches = NewIssueBatches(period)
+		}
+	}
+}
+
+// Lookup returns the configuration of host, false when it isn't configured or hosts is nil
+func (h *Hosts) Lookup(host string) (*Host, bool) {
+	if h == nil {
+		return nil, false
+	}
+	found, ok := h.hosts[strings.ToLower(host)]
+	return found, ok
+}
+
+// UsingSecret returns the names of the hosts whose credentials Secret is namespace/name
+func (h *Hosts) UsingSecret(namespace string, name string) []string {
+	if h == nil {
+		return nil
+	}
+	var names []string
+	for hostName, host := range h.hosts {
+		if ref := host.Config.CredentialsSecret; ref != nil && ref.Namespace == namespace && ref.Name == name {
+			names = append(names, hostName)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hostHTTPClient returns an HTTP client trusting the host's CA bundle as well as the system's CAs
+func hostHTTPClient(config HostConfig, timeout time.Duration) (*http.Client, error) {
+	if config.CABundle == "" {
+		return &http.Client{Timeout: timeout}, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM([]byte(config.CABundle)) {
+		return nil, fmt.Errorf("%v: the CA bundle of host %s has no PEM certificate", HOST_ERROR, config.Host)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Github Enterprise hosts", func() {
+	const RepoName = "team/project"
+	var (
+		server *httptest.Server
+		paths  []string
+	)
+
+	BeforeEach(func() {
+		paths = nil
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			paths = append(paths, req.URL.Path)
+			_, _ = w.Write([]byte(`{"number": 1, "title": "t", "state": "open"}`))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	caBundle := func() string {
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	}
+
+	It("should load the configuration from YAML", func() {
+		dir, err := ioutil.TempDir("", "hosts")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "hosts.yaml")
+		Expect(ioutil.WriteFile(path, []byte(`
+hosts:
+- host: GitHub.Example.com
+  credentialsSecret: {namespace: ops, name: ghe-token}
+`), 0600)).To(Succeed())
+		config, err := LoadHostsConfig(path)
+		Expect(err).NotTo(HaveOccurred())
+		hosts, err := NewHosts(config, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		host, ok := hosts.Lookup("github.example.com")
+		Expect(ok).To(BeTrue())
+		Expect(host.Client.(*RestClient).BaseURL).To(Equal("https://github.example.com" + EnterpriseAPIPath))
+		Expect(host.Config.CredentialsSecret).To(Equal(&HostSecretRef{Namespace: "ops", Name: "ghe-token"}))
+		_, ok = hosts.Lookup("github.com")
+		Expect(ok).To(BeFalse())
+
+		Expect(ioutil.WriteFile(path, []byte("hosts:\n- hostname: typo\n"), 0600)).To(Succeed())
+		_, err = LoadHostsConfig(path)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should call the host's API endpoint trusting its CA bundle", func() {
+		hosts, err := NewHosts(HostsConfig{Hosts: []HostConfig{{Host: "ghe.local", APIURL: server.URL + EnterpriseAPIPath, CABundle: caBundle()}}}, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		host, _ := hosts.Lookup("ghe.local")
+		_, code, err := host.Client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(Ok_Code))
+		Expect(paths).To(Equal([]string{EnterpriseAPIPath + "/repos/" + RepoName + "/issues/1"}))
+	})
+
+	It("should not trust the host's certificate without its CA bundle", func() {
+		hosts, err := NewHosts(HostsConfig{Hosts: []HostConfig{{Host: "ghe.local", APIURL: server.URL + EnterpriseAPIPath}}}, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		host, _ := hosts.Lookup("ghe.local")
+		_, _, err = host.Client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).To(HaveOccurred())
+		Expect(paths).To(BeEmpty())
+	})
+
+	It("should find the hosts using a credentials Secret", func() {
+		hosts, err := NewHosts(HostsConfig{Hosts: []HostConfig{
+			{Host: "GHE.local", CredentialsSecret: &HostSecretRef{Namespace: "ops", Name: "ghe-token"}},
+			{Host: "gitlab.local", Provider: "gitlab", CredentialsSecret: &HostSecretRef{Namespace: "ops", Name: "ghe-token"}},
+			{Host: "gitea.local", Provider: "gitea", CredentialsSecret: &HostSecretRef{Namespace: "ops", Name: "gitea-token"}},
+			{Host: "open.local"},
+		}}, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hosts.UsingSecret("ops", "ghe-token")).To(Equal([]string{"ghe.local", "gitlab.local"}))
+		Expect(hosts.UsingSecret("default", "ghe-token")).To(BeEmpty())
+		var none *Hosts
+		Expect(none.UsingSecret("ops", "ghe-token")).To(BeEmpty())
+	})
+
+	It("should reject a malformed configuration", func() {
+		for _, config := range []HostsConfig{
+			{Hosts: []HostConfig{{Host: ""}}},
+			{Hosts: []HostConfig{{Host: "ghe.local"}, {Host: "GHE.local"}}},
+			{Hosts: []HostConfig{{Host: "ghe.local", CABundle: "not a certificate"}}},
+			{Hosts: []HostConfig{{Host: "ghe.local", Provider: "bitbucket"}}},
+		} {
+			_, err := NewHosts(config, time.Second)
+			Expect(err).To(HaveOccurred())
+			Expect(strings.HasPrefix(err.Error(), HOST_ERROR)).To(BeTrue())
+		}
+	})
+})
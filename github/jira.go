@@ -0,0 +1,489 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+)
+
+// JiraClient implements Client with Jira's v2 REST API - https://docs.atlassian.com/software/jira/docs/api/REST/latest.
+// The repo of a Jira project is https://<host>/projects/<project key>, so the project key is the last part of ownerRepo,
+// and an issue's number is the numeric part of its key, e.g. 123 of OPS-123.
+// The title and description are the issue's summary and description. Jira has no state to set, so opening and closing
+// an issue take the workflow transitions of Config, and an issue is closed while its status is in the done category.
+// Jira renders the hidden HTML markers of the operator as text, so the marker ending a description or a comment is kept
+// in the JiraMarkerProperty entity property of the issue or comment instead, and put back at the end when it is read
+type JiraClient struct {
+	// BaseURL is the API root, https://<host>/rest/api/2
+	BaseURL    string
+	HTTPClient *http.Client
+	// Config is the issue type and the workflow transitions of the host's projects
+	Config JiraConfig
+}
+
+// NewJiraClient returns a JiraClient for baseURL, a nil httpClient means http.DefaultClient.
+// The unset fields of config are JiraIssueType, JiraOpenTransition and JiraCloseTransition
+func NewJiraClient(baseURL string, httpClient *http.Client, config JiraConfig) *JiraClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if config.IssueType == "" {
+		config.IssueType = JiraIssueType
+	}
+	if config.OpenTransition == "" {
+		config.OpenTransition = JiraOpenTransition
+	}
+	if config.CloseTransition == "" {
+		config.CloseTransition = JiraCloseTransition
+	}
+	return &JiraClient{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: httpClient, Config: config}
+}
+
+// jiraIssue is an issue as Jira returns it, with the entity properties asked for
+type jiraIssue struct {
+	Key        string                `json:"key"`
+	Fields     jiraIssueFields       `json:"fields"`
+	Properties map[string]jiraMarker `json:"properties,omitempty"`
+}
+
+// jiraIssueFields are the fields of an issue the operator reads
+type jiraIssueFields struct {
+	Summary     string      `json:"summary"`
+	Description string      `json:"description"`
+	Labels      []string    `json:"labels"`
+	Assignee    *jiraUser   `json:"assignee"`
+	Status      *jiraStatus `json:"status"`
+}
+
+// jiraUser is a user by its name on Jira Server and Data Center, or by its account ID on Jira Cloud
+type jiraUser struct {
+	Name      string `json:"name,omitempty"`
+	AccountID string `json:"accountId,omitempty"`
+}
+
+// jiraStatus is the workflow status of an issue, its category key is new, indeterminate or done
+type jiraStatus struct {
+	Name           string `json:"name"`
+	StatusCategory struct {
+		Key string `json:"key"`
+	} `json:"statusCategory"`
+}
+
+// jiraTransition moves an issue into the status To
+type jiraTransition struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	To   struct {
+		Name string `json:"name"`
+	} `json:"to"`
+}
+
+// jiraComment is a comment of an issue, Jira's IDs are numeric strings
+type jiraComment struct {
+	ID         string         `json:"id,omitempty"`
+	Body       string         `json:"body"`
+	Properties []jiraProperty `json:"properties,omitempty"`
+}
+
+// jiraProperty is an entity property of an issue or a comment
+type jiraProperty struct {
+	Key   string     `json:"key"`
+	Value jiraMarker `json:"value"`
+}
+
+// jiraMarker is the value of JiraMarkerProperty
+type jiraMarker struct {
+	Marker string `json:"marker"`
+}
+
+// githubIssue translates issue into a Github issue, its assignee is the only one
+func (c *JiraClient) githubIssue(issue jiraIssue) GithubRecieve {
+	translated := GithubRecieve{
+		Repo:        c.siteURL() + "/browse/" + issue.Key,
+		Title:       issue.Fields.Summary,
+		Description: joinMarker(issue.Fields.Description, issue.Properties[JiraMarkerProperty].Marker),
+		State:       trainingv1alpha1.StateOpen,
+		Number:      jiraNumber(issue.Key),
+		Key:         issue.Key,
+	}
+	if issue.Fields.Status != nil && issue.Fields.Status.StatusCategory.Key == JiraDoneStatusCategory {
+		translated.State = trainingv1alpha1.StateClosed
+	}
+	for _, label := range issue.Fields.Labels {
+		translated.Labels = append(translated.Labels, GithubLabel{Name: label})
+	}
+	if assignee := issue.Fields.Assignee; assignee != nil {
+		login := assignee.Name
+		if login == "" {
+			login = assignee.AccountID
+		}
+		translated.Assignees = []GithubUser{{Login: login}}
+	}
+	return translated
+}
+
+// CreateIssue opens a new issue of the configured type in the project of ownerRepo and moves it into the state of issueData
+func (c *JiraClient) CreateIssue(ownerRepo string, token string, issueData GithubSend) (GithubRecieve, int, error) {
+	fields := c.fields(issueData)
+	fields["project"] = map[string]string{"key": jiraProject(ownerRepo)}
+	fields["issuetype"] = map[string]string{"name": c.Config.IssueType}
+	var created jiraIssue
+	resp, body, err := c.call("POST", "/issue", issuePayload(fields, issueData), token)
+	if err != nil {
+		return GithubRecieve{}, 0, err
+	}
+	if resp.StatusCode != Created_Code {
+		return GithubRecieve{}, resp.StatusCode, nil
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		return GithubRecieve{}, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+	}
+	if issueData.State == trainingv1alpha1.StateClosed {
+		if code, err := c.transition(created.Key, c.Config.CloseTransition, token); err != nil || code != No_Content_Code {
+			return GithubRecieve{}, code, err
+		}
+	}
+	issue, code, err := c.getIssue(ownerRepo, created.Key, token)
+	if code == Ok_Code {
+		code = Created_Code
+	}
+	return issue, code, err
+}
+
+// GetIssue fetches issue number from the project of ownerRepo
+func (c *JiraClient) GetIssue(ownerRepo string, number int, token string) (GithubRecieve, int, error) {
+	return c.getIssue(ownerRepo, jiraKey(ownerRepo, number), token)
+}
+
+// UpdateIssue edits the fields set in issueData of issue number, then takes the transition into its state when it is set
+func (c *JiraClient) UpdateIssue(ownerRepo string, number int, token string, issueData GithubSend) (GithubRecieve, int, error) {
+	key := jiraKey(ownerRepo, number)
+	if fields := c.fields(issueData); len(fields) > 0 {
+		resp, _, err := c.call("PUT", "/issue/"+key, issuePayload(fields, issueData), token)
+		if err != nil {
+			return GithubRecieve{}, 0, err
+		}
+		if resp.StatusCode != No_Content_Code {
+			return GithubRecieve{}, resp.StatusCode, nil
+		}
+	}
+	if issueData.State != "" {
+		current, code, err := c.getIssue(ownerRepo, key, token)
+		if err != nil || code != Ok_Code {
+			return current, code, err
+		}
+		if current.State != issueData.State {
+			name := c.Config.OpenTransition
+			if issueData.State == trainingv1alpha1.StateClosed {
+				name = c.Config.CloseTransition
+			}
+			if code, err := c.transition(key, name, token); err != nil || code != No_Content_Code {
+				return GithubRecieve{}, code, err
+			}
+		}
+	}
+	return c.getIssue(ownerRepo, key, token)
+}
+
+// CloseIssue takes the close transition of issue number, an issue which is already done is left as is
+func (c *JiraClient) CloseIssue(ownerRepo string, number int, token string) (GithubRecieve, int, error) {
+	return c.UpdateIssue(ownerRepo, number, token, GithubSend{State: trainingv1alpha1.StateClosed})
+}
+
+// LockIssue responds 204 No Content without a call - Jira has no conversation to lock, so the Lock deletion policy only closes the issue
+func (c *JiraClient) LockIssue(ownerRepo string, number int, token string) (int, error) {
+	return No_Content_Code, nil
+}
+
+// CreateComment posts a comment with body on issue number
+func (c *JiraClient) CreateComment(ownerRepo string, number int, token string, body string) (GithubComment, int, error) {
+	return c.commentCall(ownerRepo, "POST", "/issue/"+jiraKey(ownerRepo, number)+"/comment", body, token)
+}
+
+// ListComments returns one page of the comments of issue number, paged by Github's page and per_page
+func (c *JiraClient) ListComments(ownerRepo string, number int, query url.Values, token string) ([]GithubComment, int, error) {
+	var page struct {
+		Comments []jiraComment `json:"comments"`
+	}
+	paging := jiraPage(query)
+	paging.Set("expand", "properties")
+	resp, body, err := c.call("GET", "/issue/"+jiraKey(ownerRepo, number)+"/comment?"+paging.Encode(), nil, token)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != Ok_Code {
+		return nil, resp.StatusCode, nil
+	}
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+	}
+	comments := make([]GithubComment, 0, len(page.Comments))
+	for _, comment := range page.Comments {
+		comments = append(comments, githubComment(comment))
+	}
+	return comments, resp.StatusCode, nil
+}
+
+// UpdateComment replaces the body of comment id of issue number
+func (c *JiraClient) UpdateComment(ownerRepo string, number int, id int64, token string, body string) (GithubComment, int, error) {
+	return c.commentCall(ownerRepo, "PUT", "/issue/"+jiraKey(ownerRepo, number)+"/comment/"+strconv.FormatInt(id, 10), body, token)
+}
+
+// DeleteComment deletes comment id of issue number, Jira responds 204 No Content
+func (c *JiraClient) DeleteComment(ownerRepo string, number int, id int64, token string) (int, error) {
+	resp, _, err := c.call("DELETE", "/issue/"+jiraKey(ownerRepo, number)+"/comment/"+strconv.FormatInt(id, 10), nil, token)
+	if err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+// SearchIssuesByTitle returns the issues of the project whose summary matches title by Jira's text search
+func (c *JiraClient) SearchIssuesByTitle(ownerRepo string, title string, token string) ([]GithubRecieve, int, error) {
+	jql := "project = " + jqlString(jiraProject(ownerRepo)) + " AND summary ~ " + jqlString(title)
+	return c.search(ownerRepo, jql, url.Values{"maxResults": {strconv.Itoa(PerPage)}}, token)
+}
+
+// ListIssues returns one page of the project's issues filtered by query, in Github's terms (state, labels, since, page, per_page...)
+func (c *JiraClient) ListIssues(ownerRepo string, query url.Values, token string) ([]GithubRecieve, int, error) {
+	clauses := []string{"project = " + jqlString(jiraProject(ownerRepo))}
+	switch query.Get("state") {
+	case trainingv1alpha1.StateOpen:
+		clauses = append(clauses, "statusCategory != "+JiraDoneStatusCategory)
+	case trainingv1alpha1.StateClosed:
+		clauses = append(clauses, "statusCategory = "+JiraDoneStatusCategory)
+	}
+	if labels := query.Get("labels"); labels != "" {
+		for _, label := range strings.Split(labels, ",") {
+			clauses = append(clauses, "labels = "+jqlString(label))
+		}
+	}
+	if since, err := time.Parse(time.RFC3339, query.Get("since")); err == nil {
+		// JQL's dates are in the user's time zone, a relative one isn't
+		clauses = append(clauses, fmt.Sprintf("updated >= -%dm", int(time.Since(since).Minutes())+1))
+	}
+	return c.search(ownerRepo, strings.Join(clauses, " AND ")+" ORDER BY created DESC", jiraPage(query), token)
+}
+
+// search returns the issues matching jql, paged by page's startAt and maxResults
+func (c *JiraClient) search(ownerRepo string, jql string, page url.Values, token string) ([]GithubRecieve, int, error) {
+	var found struct {
+		Issues []jiraIssue `json:"issues"`
+	}
+	page.Set("jql", jql)
+	page.Set("fields", "summary,description,status,labels,assignee")
+	page.Set("properties", JiraMarkerProperty)
+	resp, body, err := c.call("GET", "/search?"+page.Encode(), nil, token)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != Ok_Code {
+		return nil, resp.StatusCode, nil
+	}
+	if err := json.Unmarshal(body, &found); err != nil {
+		return nil, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+	}
+	translated := make([]GithubRecieve, 0, len(found.Issues))
+	for _, issue := range found.Issues {
+		translated = append(translated, c.githubIssue(issue))
+	}
+	return translated, resp.StatusCode, nil
+}
+
+// fields returns the Jira fields of the set fields of issueData, Jira has a single assignee and no milestone
+func (c *JiraClient) fields(issueData GithubSend) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if issueData.Title != "" {
+		fields["summary"] = issueData.Title
+	}
+	if issueData.Body != "" {
+		fields["description"], _ = splitMarker(issueData.Body)
+	}
+	if issueData.Labels != nil { // an empty list removes every label
+		fields["labels"] = issueData.Labels
+	}
+	if len(issueData.Assignees) > 0 {
+		fields["assignee"] = jiraUser{Name: issueData.Assignees[0]}
+	}
+	return fields
+}
+
+// transition takes the transition of issue key which is named name or leads to the status named name, ignoring the case.
+// It returns 422, as Github does for an invalid field, when the issue's workflow has no such transition from its current status
+func (c *JiraClient) transition(key string, name string, token string) (int, error) {
+	var available struct {
+		Transitions []jiraTransition `json:"transitions"`
+	}
+	resp, body, err := c.call("GET", "/issue/"+key+"/transitions", nil, token)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != Ok_Code {
+		return resp.StatusCode, nil
+	}
+	if err := json.Unmarshal(body, &available); err != nil {
+		return resp.StatusCode, requestError(key, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+	}
+	for _, transition := range available.Transitions {
+		if strings.EqualFold(transition.Name, name) || strings.EqualFold(transition.To.Name, name) {
+			resp, _, err := c.call("POST", "/issue/"+key+"/transitions", map[string]interface{}{"transition": map[string]string{"id": transition.ID}}, token)
+			if err != nil {
+				return 0, err
+			}
+			return resp.StatusCode, nil
+		}
+	}
+	return 422, nil
+}
+
+// getIssue fetches issue key with the fields the operator reads
+func (c *JiraClient) getIssue(ownerRepo string, key string, token string) (GithubRecieve, int, error) {
+	var issue jiraIssue
+	resp, body, err := c.call("GET", "/issue/"+key+"?fields=summary,description,status,labels,assignee&properties="+JiraMarkerProperty, nil, token)
+	if err != nil {
+		return GithubRecieve{}, 0, err
+	}
+	if resp.StatusCode != Ok_Code {
+		return GithubRecieve{}, resp.StatusCode, nil
+	}
+	if err := json.Unmarshal(body, &issue); err != nil {
+		return GithubRecieve{}, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+	}
+	return c.githubIssue(issue), resp.StatusCode, nil
+}
+
+// commentCall sends a comment with body, its marker as an entity property, and decodes the comment of a successful response
+func (c *JiraClient) commentCall(ownerRepo string, method string, path string, body string, token string) (GithubComment, int, error) {
+	var comment jiraComment
+	text, marker := splitMarker(body)
+	resp, respBody, err := c.call(method, path+"?expand=properties", jiraComment{Body: text, Properties: markerProperties(marker)}, token)
+	if err != nil {
+		return GithubComment{}, 0, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := json.Unmarshal(respBody, &comment); err != nil {
+			return GithubComment{}, resp.StatusCode, requestError(ownerRepo, resp.StatusCode, fmt.Errorf("can't decode the response: %w", err))
+		}
+	}
+	return githubComment(comment), resp.StatusCode, nil
+}
+
+// siteURL returns the web root of the client's Jira, its BaseURL without the API path
+func (c *JiraClient) siteURL() string {
+	return strings.TrimSuffix(c.BaseURL, JiraAPIPath)
+}
+
+// call sends payload (if it isn't nil) as JSON to path under the client's BaseURL and reads the whole response.
+// A token of the form <email>:<API token> authenticates with basic auth, as Jira Cloud expects,
+// any other token is a Jira Server or Data Center personal access token. The token is redacted from its error
+func (c *JiraClient) call(method string, path string, payload interface{}, token string) (*http.Response, []byte, error) {
+	authorization := "Bearer " + token
+	if strings.Contains(token, ":") {
+		authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(token))
+	}
+	resp, body, err := sendJSON(c.HTTPClient, c.BaseURL, method, path, payload, http.Header{"Authorization": {authorization}})
+	return resp, body, RedactError(err, token)
+}
+
+// githubComment translates comment into a Github comment, whose body ends with the marker of its entity property
+func githubComment(comment jiraComment) GithubComment {
+	id, _ := strconv.ParseInt(comment.ID, 10, 64)
+	var marker string
+	for _, property := range comment.Properties {
+		if property.Key == JiraMarkerProperty {
+			marker = property.Value.Marker
+		}
+	}
+	return GithubComment{ID: id, Body: joinMarker(comment.Body, marker)}
+}
+
+// issuePayload returns the payload creating or editing an issue with fields, with the marker ending issueData's body as an entity property
+func issuePayload(fields map[string]interface{}, issueData GithubSend) map[string]interface{} {
+	payload := map[string]interface{}{"fields": fields}
+	if _, marker := splitMarker(issueData.Body); marker != "" {
+		payload["properties"] = markerProperties(marker)
+	}
+	return payload
+}
+
+// markerProperties returns the entity property holding marker, none when it is empty
+func markerProperties(marker string) []jiraProperty {
+	if marker == "" {
+		return nil
+	}
+	return []jiraProperty{{Key: JiraMarkerProperty, Value: jiraMarker{Marker: marker}}}
+}
+
+// splitMarker returns body without the marker of the operator which ends it (see IssueBody and CommentBody), and the marker
+func splitMarker(body string) (string, string) {
+	start := strings.LastIndex(body, "<!-- "+MarkerPrefix)
+	if start < 0 || !strings.HasSuffix(body, " -->") {
+		return body, ""
+	}
+	return strings.TrimSuffix(body[:start], "\n\n"), body[start:]
+}
+
+// joinMarker returns text followed by marker as IssueBody and CommentBody join them, text as it is without a marker
+func joinMarker(text string, marker string) string {
+	if marker == "" {
+		return text
+	}
+	return text + "\n\n" + marker
+}
+
+// jiraPage translates Github's page and per_page of query into Jira's startAt and maxResults
+func jiraPage(query url.Values) url.Values {
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = PerPage
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	return url.Values{"startAt": {strconv.Itoa((page - 1) * perPage)}, "maxResults": {strconv.Itoa(perPage)}}
+}
+
+// jiraProject returns the project key of ownerRepo, its last part
+func jiraProject(ownerRepo string) string {
+	return ownerRepo[strings.LastIndex(ownerRepo, "/")+1:]
+}
+
+// jiraKey returns the key of issue number of the project of ownerRepo
+func jiraKey(ownerRepo string, number int) string {
+	return jiraProject(ownerRepo) + "-" + strconv.Itoa(number)
+}
+
+// jiraNumber returns the number of the issue key, the part after its last dash
+func jiraNumber(key string) int {
+	number, _ := strconv.Atoi(key[strings.LastIndex(key, "-")+1:])
+	return number
+}
+
+// jqlString quotes value as a JQL string
+func jqlString(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
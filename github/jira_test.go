@@ -0,0 +1,297 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+)
+
+var _ = Describe("Jira provider", func() {
+	const RepoName = "projects/OPS"
+	var (
+		server      *httptest.Server
+		mu          sync.Mutex
+		issues      map[string]map[string]interface{} // the fields of the project's issues by key
+		properties  map[string]interface{}            // the entity properties of the project's issues by key
+		comments    map[string]jiraComment
+		nextID      int
+		transitions []string
+		auth        []string
+		githubi     trainingv1alpha1.GithubIssue
+	)
+
+	// a stand-in for a Jira server whose workflow goes To Do -> In Progress -> Done, with the transitions
+	// Start (11), Resolve (21) into Done and Reopen (31) into To Do
+	BeforeEach(func() {
+		issues, comments, nextID, transitions, auth = map[string]map[string]interface{}{}, map[string]jiraComment{}, 10000, nil, nil
+		properties = map[string]interface{}{}
+		// entityProperties returns the properties sent in data as Jira returns them for an issue
+		entityProperties := func(data map[string]interface{}) map[string]interface{} {
+			sent, _ := data["properties"].([]interface{})
+			if len(sent) == 0 {
+				return nil
+			}
+			property := sent[0].(map[string]interface{})
+			return map[string]interface{}{property["key"].(string): property["value"]}
+		}
+		// commentProperties returns the properties sent in data as Jira returns them for a comment
+		commentProperties := func(data map[string]interface{}) []jiraProperty {
+			var decoded []jiraProperty
+			encoded, _ := json.Marshal(data["properties"])
+			_ = json.Unmarshal(encoded, &decoded)
+			return decoded
+		}
+		workflow := []map[string]interface{}{
+			{"id": "11", "name": "Start", "to": map[string]string{"name": "In Progress"}},
+			{"id": "21", "name": "Resolve", "to": map[string]string{"name": "Done"}},
+			{"id": "31", "name": "Reopen", "to": map[string]string{"name": "To Do"}},
+		}
+		statuses := map[string]map[string]interface{}{
+			"11": {"name": "In Progress", "statusCategory": map[string]string{"key": "indeterminate"}},
+			"21": {"name": "Done", "statusCategory": map[string]string{"key": "done"}},
+			"31": {"name": "To Do", "statusCategory": map[string]string{"key": "new"}},
+		}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			auth = append(auth, req.Header.Get("Authorization"))
+			var data map[string]interface{}
+			_ = json.NewDecoder(req.Body).Decode(&data)
+			parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, JiraAPIPath), "/"), "/")
+			switch {
+			case parts[0] == "search":
+				jql := req.URL.Query().Get("jql")
+				Expect(jql).To(HavePrefix(`project = "OPS"`))
+				found := []map[string]interface{}{}
+				for number := 1; number <= len(issues); number++ {
+					key := "OPS-" + strconv.Itoa(number)
+					summary, _ := issues[key]["summary"].(string)
+					if !strings.Contains(jql, "summary ~") || strings.Contains(jql, strconv.Quote(summary)) {
+						Expect(req.URL.Query().Get("properties")).To(Equal(JiraMarkerProperty))
+						found = append(found, map[string]interface{}{"key": key, "fields": issues[key], "properties": properties[key]})
+					}
+				}
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"issues": found})
+			case len(parts) == 1 && req.Method == "POST":
+				fields := data["fields"].(map[string]interface{})
+				Expect(fields["project"]).To(Equal(map[string]interface{}{"key": "OPS"}))
+				if assignee, ok := fields["assignee"].(map[string]interface{}); ok && assignee["name"] != "alice" {
+					w.WriteHeader(http.StatusBadRequest)
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": map[string]string{"assignee": "User does not exist"}})
+					return
+				}
+				key := "OPS-" + strconv.Itoa(len(issues)+1)
+				fields["status"] = statuses["31"]
+				issues[key] = fields
+				properties[key] = entityProperties(data)
+				w.WriteHeader(http.StatusCreated)
+				_ = json.NewEncoder(w).Encode(map[string]string{"key": key})
+			default:
+				issue, ok := issues[parts[1]]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				switch {
+				case len(parts) == 2 && req.Method == "PUT":
+					for field, value := range data["fields"].(map[string]interface{}) {
+						issue[field] = value
+					}
+					if sent := entityProperties(data); sent != nil {
+						properties[parts[1]] = sent
+					}
+					w.WriteHeader(http.StatusNoContent)
+				case len(parts) == 2:
+					Expect(req.URL.Query().Get("properties")).To(Equal(JiraMarkerProperty))
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{"key": parts[1], "fields": issue, "properties": properties[parts[1]]})
+				case parts[2] == "transitions" && req.Method == "POST":
+					id := data["transition"].(map[string]interface{})["id"].(string)
+					transitions = append(transitions, id)
+					issue["status"] = statuses[id]
+					w.WriteHeader(http.StatusNoContent)
+				case parts[2] == "transitions":
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{"transitions": workflow})
+				case len(parts) == 3 && req.Method == "POST":
+					comment := jiraComment{ID: strconv.Itoa(nextID), Body: data["body"].(string), Properties: commentProperties(data)}
+					comments[comment.ID] = comment
+					nextID++
+					w.WriteHeader(http.StatusCreated)
+					_ = json.NewEncoder(w).Encode(comment)
+				case len(parts) == 3:
+					Expect(req.URL.Query().Get("expand")).To(Equal("properties"))
+					listed := []jiraComment{}
+					if req.URL.Query().Get("startAt") == "0" {
+						for id := 10000; id < nextID; id++ {
+							if comment, ok := comments[strconv.Itoa(id)]; ok {
+								listed = append(listed, comment)
+							}
+						}
+					}
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{"comments": listed})
+				case req.Method == "DELETE":
+					delete(comments, parts[3])
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					comments[parts[3]] = jiraComment{ID: parts[3], Body: data["body"].(string), Properties: commentProperties(data)}
+					_ = json.NewEncoder(w).Encode(comments[parts[3]])
+				}
+			}
+		}))
+		githubi = trainingv1alpha1.GithubIssue{Spec: trainingv1alpha1.GithubIssueSpec{
+			Repo:        "https://example.atlassian.net/" + RepoName,
+			Title:       "Jira issue",
+			Description: "Hi from testing K8s",
+			Labels:      []string{"ops"},
+			Assignees:   []string{"alice"},
+		}}
+		githubi.UID = "0f4e6d21-5a0b-4c53-9e57-1d0b8f3b2c7a"
+		githubi.Finalizers = []string{FinalizerName}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	client := func(config JiraConfig) *JiraClient {
+		return NewJiraClient(server.URL+JiraAPIPath, server.Client(), config)
+	}
+
+	It("should create an issue with its summary and description and record its key", func() {
+		githubi, err, _ := GetIssue(client(JiraConfig{}), githubi, RepoName, "pm@example.com:api-token", "POST")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(githubi.Status.Key).To(Equal("OPS-1"))
+		Expect(githubi.Status.Number).To(Equal(1))
+		Expect(githubi.Status.State).To(Equal(trainingv1alpha1.StateOpen))
+		Expect(githubi.Status.Labels).To(ConsistOf("ops"))
+		Expect(githubi.Status.Assignees).To(ConsistOf("alice"))
+		Expect(issues["OPS-1"]["summary"]).To(Equal("Jira issue"))
+		Expect(issues["OPS-1"]["description"]).To(Equal("Hi from testing K8s")) // Jira would show the marker in the description
+		Expect(properties["OPS-1"]).To(Equal(map[string]interface{}{JiraMarkerProperty: map[string]interface{}{"marker": Marker(githubi)}}))
+		Expect(issues["OPS-1"]["issuetype"]).To(Equal(map[string]interface{}{"name": JiraIssueType}))
+		for _, header := range auth {
+			Expect(header).To(Equal("Basic " + base64.StdEncoding.EncodeToString([]byte("pm@example.com:api-token"))))
+		}
+	})
+
+	It("should close and reopen an issue with the configured transitions", func() {
+		jira := client(JiraConfig{CloseTransition: "Resolve"})
+		githubi, err, _ := GetIssue(jira, githubi, RepoName, "pat", "POST")
+		Expect(err).NotTo(HaveOccurred())
+		githubi.Spec.Title = "Jira issue, renamed"
+		githubi.Spec.State = trainingv1alpha1.StateClosed
+		githubi, err, updated := GetIssue(jira, githubi, RepoName, "pat", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated).To(BeTrue())
+		Expect(githubi.Status.Title).To(Equal("Jira issue, renamed"))
+		Expect(githubi.Status.State).To(Equal(trainingv1alpha1.StateClosed))
+		Expect(transitions).To(Equal([]string{"21"}))
+
+		_, err, updated = GetIssue(jira, githubi, RepoName, "pat", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated).To(BeFalse())
+
+		githubi.Spec.State = trainingv1alpha1.StateOpen
+		githubi, err, _ = GetIssue(jira, githubi, RepoName, "pat", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(githubi.Status.State).To(Equal(trainingv1alpha1.StateOpen))
+		Expect(transitions).To(Equal([]string{"21", "31"})) // To Do is the target status of Reopen
+		Expect(auth).To(ContainElement("Bearer pat"))
+	})
+
+	It("should reject a transition the workflow doesn't have as Github rejects an invalid field", func() {
+		jira := client(JiraConfig{CloseTransition: "Won't Do"})
+		githubi, err, _ := GetIssue(jira, githubi, RepoName, "pat", "POST")
+		Expect(err).NotTo(HaveOccurred())
+		githubi.Spec.State = trainingv1alpha1.StateClosed
+		_, err, _ = GetIssue(jira, githubi, RepoName, "pat", "GET")
+		var invalid *ValidationError
+		Expect(errors.As(err, &invalid)).To(BeTrue())
+		Expect(transitions).To(BeEmpty())
+	})
+
+	It("should find an issue by its marker and adopt one by its summary", func() {
+		githubi.CreationTimestamp.Time = time.Now()
+		created, err, _ := GetIssue(client(JiraConfig{}), githubi, RepoName, "pat", "POST")
+		Expect(err).NotTo(HaveOccurred())
+		_, number, err := FindIssueToAdopt(client(JiraConfig{}), githubi, RepoName, "pat")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(number).To(Equal(created.Status.Number))
+
+		other := githubi
+		other.UID = "another-uid"
+		other.Spec.AdoptByTitle = true
+		_, number, err = FindIssueToAdopt(client(JiraConfig{}), other, RepoName, "pat")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(number).To(Equal(created.Status.Number))
+	})
+
+	It("should sync comments and close the issue on deletion", func() {
+		jira := client(JiraConfig{})
+		githubi, err, _ := GetIssue(jira, githubi, RepoName, "pat", "POST")
+		Expect(err).NotTo(HaveOccurred())
+		githubi.Spec.Comments = []trainingv1alpha1.IssueComment{{Key: "deploy", Body: "deploy of v1.4 finished"}}
+		githubi, _, err = SyncComments(jira, githubi, RepoName, "pat")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(githubi.Status.Comments).To(HaveLen(1))
+		Expect(githubi.Status.Comments[0].ID).To(Equal(int64(10000)))
+		Expect(comments["10000"].Body).To(Equal("deploy of v1.4 finished"))
+		Expect(comments["10000"].Properties[0].Value.Marker).To(Equal(CommentMarkerPrefix(githubi) + "deploy -->"))
+		githubi.Status.Comments = nil // a lost ID is found again by the marker
+		githubi, changed, err := SyncComments(jira, githubi, RepoName, "pat")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeFalse())
+		Expect(githubi.Status.Comments[0].ID).To(Equal(int64(10000)))
+
+		githubi.Spec.Comments[0].Body = "deploy of v1.5 finished"
+		githubi, changed, err = SyncComments(jira, githubi, RepoName, "pat")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(comments["10000"].Body).To(Equal("deploy of v1.5 finished"))
+
+		githubi.Spec.DeletionPolicy = trainingv1alpha1.DeletionPolicyLock
+		githubi, err = DeleteIssue(jira, githubi, RepoName, "pat")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(githubi.Finalizers).To(BeEmpty())
+		Expect(transitions).To(Equal([]string{"21"})) // Done is the target status of Resolve
+	})
+
+	It("should guess Jira from the host's name and configure its transitions", func() {
+		Expect(ProviderFor("", "example.atlassian.net", nil)).To(Equal(trainingv1alpha1.ProviderJira))
+		Expect(ProviderFor("", "jira.example.com", nil)).To(Equal(trainingv1alpha1.ProviderJira))
+
+		hosts, err := NewHosts(HostsConfig{Hosts: []HostConfig{
+			{Host: "jira.example.com", Jira: &JiraConfig{IssueType: "Bug"}},
+		}}, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		host, _ := hosts.Lookup("jira.example.com")
+		jira := host.Client.(*JiraClient)
+		Expect(jira.BaseURL).To(Equal("https://jira.example.com" + JiraAPIPath))
+		Expect(jira.Config).To(Equal(JiraConfig{IssueType: "Bug", OpenTransition: JiraOpenTransition, CloseTransition: JiraCloseTransition}))
+	})
+})
@@ -0,0 +1,71 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// apiRequests counts the Github API calls by method, status code ("error" without a response) and API host
+	apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "githubissues_github_api_requests_total",
+		Help: "Github API calls by method, status code and host",
+	}, []string{"method", "code", "host"})
+
+	// apiRequestDuration is the latency of the Github API calls, until the whole response is read
+	apiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "githubissues_github_api_request_duration_seconds",
+		Help:    "Latency of the Github API calls by method and host",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 10), // 50ms to ~25s
+	}, []string{"method", "host"})
+
+	// driftDetections counts the fields found to differ between an issue on Github and its spec, by the field's sync mode
+	driftDetections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "githubissues_drift_detections_total",
+		Help: "Fields of issues found to differ from their GithubIssue's spec, by field and sync mode",
+	}, []string{"field", "mode"})
+
+	// rateLimitRemaining exports the remaining budget of each credential, labeled by its fingerprint and never by the token itself
+	rateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "githubissues_github_rate_limit_remaining",
+		Help: "Github API calls left in the current rate limit window, by credential fingerprint",
+	}, []string{"credential"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(apiRequests, apiRequestDuration, driftDetections, rateLimitRemaining)
+}
+
+// observeCall records a call to baseURL which took since start, code is zero when it got no response
+func observeCall(baseURL string, method string, code int, start time.Time) {
+	host := baseURL
+	if parsed, err := url.Parse(baseURL); err == nil && parsed.Host != "" {
+		host = parsed.Host
+	}
+	status := "error"
+	if code != 0 {
+		status = strconv.Itoa(code)
+	}
+	apiRequests.WithLabelValues(method, status, host).Inc()
+	apiRequestDuration.WithLabelValues(method, host).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+)
+
+var _ = Describe("Github metrics", func() {
+	const RepoName = "razo7/githubissues-operator"
+	var (
+		server *httptest.Server
+		host   string
+		code   int
+	)
+
+	BeforeEach(func() {
+		code = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(code)
+			_ = json.NewEncoder(w).Encode(GithubRecieve{Number: 7, State: "open", Title: "edited on Github"})
+		}))
+		parsed, err := url.Parse(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		host = parsed.Host
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should count the calls by method, status code and host and observe their latency", func() {
+		gc := NewClient(server.URL, server.Client())
+		_, _, err := gc.GetIssue(RepoName, 7, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		code = http.StatusNotFound
+		_, _, err = gc.GetIssue(RepoName, 8, "abc")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(testutil.ToFloat64(apiRequests.WithLabelValues("GET", "200", host))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(apiRequests.WithLabelValues("GET", "404", host))).To(Equal(1.0))
+		Expect(testutil.CollectAndCount(apiRequestDuration, "githubissues_github_api_request_duration_seconds")).To(BeNumerically(">=", 1))
+	})
+
+	It("should count a call without a response as an error", func() {
+		gc := NewClient(server.URL, server.Client())
+		server.Close()
+		_, _, err := gc.CloseIssue(RepoName, 7, "abc")
+		Expect(err).To(HaveOccurred())
+		Expect(testutil.ToFloat64(apiRequests.WithLabelValues("PATCH", "error", host))).To(Equal(1.0))
+	})
+
+	It("should count the drifted fields by sync mode", func() {
+		reportTitle := testutil.ToFloat64(driftDetections.WithLabelValues(FieldTitle, string(trainingv1alpha1.SyncModeReportOnly)))
+		githubi := trainingv1alpha1.GithubIssue{Spec: trainingv1alpha1.GithubIssueSpec{
+			Title:      "t",
+			SyncPolicy: &trainingv1alpha1.SyncPolicy{Title: trainingv1alpha1.SyncModeReportOnly},
+		}}
+		githubi.Status.Number = 7
+		_, err, _ := GetIssue(NewClient(server.URL, server.Client()), githubi, RepoName, "abc", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(testutil.ToFloat64(driftDetections.WithLabelValues(FieldTitle, string(trainingv1alpha1.SyncModeReportOnly)))).To(Equal(reportTitle + 1))
+	})
+})
@@ -0,0 +1,58 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"net"
+	"strings"
+
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+)
+
+// ProviderFor returns the provider of a repo on host - explicit (spec.provider) when it is set, then the provider
+// of host in hosts, and otherwise the one guessed from host's name
+func ProviderFor(explicit string, host string, hosts *Hosts) string {
+	if explicit != "" {
+		return explicit
+	}
+	if configured, ok := hosts.Lookup(host); ok {
+		return configured.Config.Provider
+	}
+	return guessProvider(host)
+}
+
+// guessProvider returns gitlab for a host with a gitlab label in its name, e.g. gitlab.com or gitlab.example.com,
+// gitea for a host with a gitea or forgejo label, jira for a host with a jira label or on atlassian.net, and github otherwise
+func guessProvider(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	if strings.HasSuffix(strings.ToLower(host), ".atlassian.net") {
+		return trainingv1alpha1.ProviderJira
+	}
+	for _, label := range strings.Split(strings.ToLower(host), ".") {
+		switch label {
+		case trainingv1alpha1.ProviderGitlab:
+			return trainingv1alpha1.ProviderGitlab
+		case trainingv1alpha1.ProviderGitea, "forgejo":
+			return trainingv1alpha1.ProviderGitea
+		case trainingv1alpha1.ProviderJira:
+			return trainingv1alpha1.ProviderJira
+		}
+	}
+	return trainingv1alpha1.ProviderGithub
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitError is returned instead of making a call while the credential's budget is exhausted,
+// and for a response rejected by a primary or secondary rate limit - https://docs.github.com/en/rest/overview/resources-in-the-rest-api#rate-limiting
+type RateLimitError struct {
+	// ResetAt is when calls with the credential may be made again
+	ResetAt time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v: rate limited until %s", RATE_LIMIT_ERROR, e.ResetAt.UTC().Format(time.RFC3339))
+}
+
+// RetryAfter returns how long to wait from now before calling again, at least a second
+func (e *RateLimitError) RetryAfter(now time.Time) time.Duration {
+	if wait := e.ResetAt.Sub(now); wait > time.Second {
+		return wait
+	}
+	return time.Second
+}
+
+// RateLimiter tracks the rate limit budget of every credential from the X-RateLimit-* and Retry-After response headers.
+// It is shared by all the reconciles, so once a credential runs out no call is made with it until the limit resets.
+type RateLimiter struct {
+	// Now returns the current time, it is replaced in tests
+	Now func() time.Time
+
+	mu      sync.Mutex
+	budgets map[string]rateBudget // credential fingerprint -> budget
+}
+
+type rateBudget struct {
+	remaining int
+	reset     time.Time // when the budget is refilled
+	retryAt   time.Time // set by Retry-After or a rejected call, no call is made before it
+}
+
+// NewRateLimiter returns an empty RateLimiter, every credential has a budget until a response says otherwise
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{Now: time.Now, budgets: map[string]rateBudget{}}
+}
+
+// Wait returns a RateLimitError while token is paused, a nil RateLimiter never pauses
+func (l *RateLimiter) Wait(token string) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	budget, ok := l.budgets[fingerprint(token)]
+	if !ok {
+		return nil
+	}
+	now := l.Now()
+	if now.Before(budget.retryAt) {
+		return &RateLimitError{ResetAt: budget.retryAt}
+	}
+	if budget.remaining == 0 && now.Before(budget.reset) {
+		return &RateLimitError{ResetAt: budget.reset}
+	}
+	return nil
+}
+
+// Observe records the budget resp reports for token, it returns a RateLimitError when resp was rejected by a rate limit.
+// A 403 is a rate limit only when the budget is exhausted, Retry-After is set or the body says so, otherwise it is a permission error
+func (l *RateLimiter) Observe(token string, resp *http.Response, body []byte) error {
+	if l == nil {
+		return nil
+	}
+	return l.observe(token, resp, body, false)
+}
+
+// ObserveRateLimited records the budget resp reports for token and returns the RateLimitError of resp, whose body reports
+// a rate limit its status code doesn't - as a GraphQL query does, with a 200 and a RATE_LIMITED error
+func (l *RateLimiter) ObserveRateLimited(token string, resp *http.Response) error {
+	if l == nil {
+		return &RateLimitError{ResetAt: time.Now().Add(RateLimitBackoff)}
+	}
+	return l.observe(token, resp, nil, true)
+}
+
+// observe is Observe, resp is known to be rejected by a rate limit when rateLimited is true
+func (l *RateLimiter) observe(token string, resp *http.Response, body []byte, rateLimited bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.Now()
+	key := fingerprint(token)
+	budget, ok := l.budgets[key]
+	if !ok {
+		budget.remaining = -1 // unknown
+	}
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		budget.remaining = remaining
+		rateLimitRemaining.WithLabelValues(key).Set(float64(remaining))
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		budget.reset = time.Unix(reset, 0)
+	}
+	retryAfter := resp.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(retryAfter); err == nil {
+		budget.retryAt = now.Add(time.Duration(seconds) * time.Second)
+	}
+	limited := rateLimited || resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusForbidden && (budget.remaining == 0 || retryAfter != "" || strings.Contains(strings.ToLower(string(body)), "rate limit")))
+	if limited && !now.Before(budget.retryAt) && (budget.remaining != 0 || !now.Before(budget.reset)) {
+		budget.retryAt = now.Add(RateLimitBackoff) // the response doesn't say when to retry
+	}
+	l.budgets[key] = budget
+	if !limited {
+		return nil
+	}
+	if now.Before(budget.retryAt) {
+		return &RateLimitError{ResetAt: budget.retryAt}
+	}
+	return &RateLimitError{ResetAt: budget.reset}
+}
+
+// fingerprint identifies a credential without revealing it
+func fingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:6])
+}
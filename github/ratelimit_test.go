@@ -0,0 +1,128 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+var _ = Describe("Github rate limits", func() {
+	const RepoName = "razo7/githubissues-operator"
+	var (
+		server    *httptest.Server
+		client    *RestClient
+		now       time.Time
+		calls     int
+		code      int
+		remaining int
+		headers   http.Header
+		body      string
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+		calls, code, remaining, headers, body = 0, Ok_Code, 4999, http.Header{}, `{"number": 1, "title": "t", "state": "open"}`
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			calls++
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(now.Add(time.Hour).Unix(), 10))
+			for key, values := range headers {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(code)
+			_, _ = w.Write([]byte(body))
+		}))
+		client = NewClient(server.URL, server.Client())
+		client.RateLimits.Now = func() time.Time { return now }
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should export the remaining budget without the token", func() {
+		_, _, err := client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(testutil.ToFloat64(rateLimitRemaining.WithLabelValues(fingerprint("a-token")))).To(Equal(4999.0))
+		Expect(fingerprint("a-token")).NotTo(ContainSubstring("a-token"))
+	})
+
+	It("should pause the credential until the reset once its budget is exhausted", func() {
+		remaining = 0
+		_, _, err := client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = client.GetIssue(RepoName, 1, "a-token")
+		var limited *RateLimitError
+		Expect(errors.As(err, &limited)).To(BeTrue())
+		Expect(limited.ResetAt).To(BeTemporally("==", now.Add(time.Hour)))
+		Expect(limited.RetryAfter(now)).To(Equal(time.Hour))
+		Expect(calls).To(Equal(1))
+
+		By("leave other credentials alone")
+		_, _, err = client.GetIssue(RepoName, 1, "another-token")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("call again after the reset")
+		now = now.Add(time.Hour)
+		remaining = 4999
+		_, _, err = client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should tell a rate limit 403 from a permission 403", func() {
+		code, body = http.StatusForbidden, `{"message": "Resource not accessible by integration"}`
+		_, status, err := client.GetIssue(RepoName, 1, "a-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status).To(Equal(http.StatusForbidden))
+
+		body = `{"message": "You have exceeded a secondary rate limit."}`
+		headers.Set("Retry-After", "30")
+		_, _, err = client.GetIssue(RepoName, 1, "a-token")
+		var limited *RateLimitError
+		Expect(errors.As(err, &limited)).To(BeTrue())
+		Expect(limited.ResetAt).To(BeTemporally("==", now.Add(30*time.Second)))
+	})
+
+	It("should back off after a 429 which doesn't say when to retry", func() {
+		code = http.StatusTooManyRequests
+		_, _, err := client.GetIssue(RepoName, 1, "a-token")
+		var limited *RateLimitError
+		Expect(errors.As(err, &limited)).To(BeTrue())
+		Expect(limited.ResetAt).To(BeTemporally("==", now.Add(RateLimitBackoff)))
+	})
+
+	It("should report a rate limited GithubIssue with the RateLimited reason and keep its state", func() {
+		remaining = 0
+		githubi := trainingv1alpha1.GithubIssue{Spec: trainingv1alpha1.GithubIssueSpec{Title: "t"}, Status: trainingv1alpha1.GithubIssueStatus{Number: 1, State: "open"}}
+		githubi, err, _ := GetIssue(client, githubi, RepoName, "a-token", "GET")
+		Expect(err).NotTo(HaveOccurred())
+		githubi, err, _ = GetIssue(client, githubi, RepoName, "a-token", "GET")
+		Expect(err).To(HaveOccurred())
+		Expect(meta.FindStatusCondition(githubi.Status.Conditions, trainingv1alpha1.ConditionSynced).Reason).To(Equal(trainingv1alpha1.ReasonRateLimited))
+		Expect(githubi.Status.State).NotTo(Equal(Fail_Repo))
+	})
+})
@@ -0,0 +1,64 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces a credential in errors, logs, Events and status
+const Redacted = "[REDACTED]"
+
+// tokenPattern matches Github's token formats - personal access, OAuth, App installation, user-to-server and refresh tokens,
+// and fine-grained personal access tokens - https://github.blog/2021-04-05-behind-githubs-new-authentication-token-formats/
+var tokenPattern = regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{20,})\b`)
+
+// Redact replaces the secrets in text, and anything formatted like a Github token, with Redacted. Empty secrets are ignored
+func Redact(text string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			text = strings.ReplaceAll(text, secret, Redacted)
+		}
+	}
+	return tokenPattern.ReplaceAllString(text, Redacted)
+}
+
+// RedactError returns err with the secrets redacted from its message, errors.As and errors.Is still see the wrapped error
+func RedactError(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+	message := Redact(err.Error(), secrets...)
+	if message == err.Error() {
+		return err
+	}
+	return &redactedError{err: err, message: message}
+}
+
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
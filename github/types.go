@@ -16,36 +16,179 @@ limitations under the License.
 
 package github
 
+import "time"
+
 const (
-	Fail_Repo     = "Fail repo"
-	Created_Code  = 201 // https://docs.github.com/en/rest/reference/issues#create-an-issue
-	Ok_Code       = 200
-	FinalizerName = "batch.tutorial.kubebuilder.io/finalizer"
-
-	REST_ERROR = "REST API error"
-	HTTP_ERROR = "Repo or Token error"
-	JSON_ERROR = "Parsing error"
-
-	PATCH = "PATCH call"
-	POST  = "POST call"
-	GET   = "GET call"
+	Fail_Repo         = "Fail repo"
+	Created_Code      = 201 // https://docs.github.com/en/rest/reference/issues#create-an-issue
+	Ok_Code           = 200
+	No_Content_Code   = 204
+	Not_Modified_Code = 304 // a conditional GET of an unchanged issue
+	FinalizerName     = "batch.tutorial.kubebuilder.io/finalizer"
+
+	DefaultSecretKey = "github-token" // the key of the token in a credentials Secret when spec.credentialsSecretRef.key is empty
+
+	AppIDKey         = "app-id"      // the key of a Github App's ID in a credentials Secret
+	AppPrivateKeyKey = "private-key" // the key of a Github App's PEM private key in a credentials Secret
+
+	AppTokenRefreshMargin = 5 * time.Minute // an installation token is minted again once it expires within this margin
+
+	MarkerPrefix   = "githubissues-operator:uid=" // the hidden marker in an issue's body is <!-- githubissues-operator:uid=<UID> -->
+	PerPage        = 100                          // the page size of list calls, Github's maximum
+	MaxMarkerPages = 10                           // how many pages of recently updated issues are looked through for a marker
+
+	CommentMarkerKey = ":comment=" // a comment's marker is <!-- githubissues-operator:uid=<UID>:comment=<key> -->
+
+	// the fields of an issue, as named in drift reports
+	FieldTitle     = "title"
+	FieldBody      = "body"
+	FieldLabels    = "labels"
+	FieldAssignees = "assignees"
+	FieldMilestone = "milestone"
+	FieldState     = "state"
+
+	SignaturePrefix   = "sha256="       // X-Hub-Signature-256 is sha256=<hex HMAC>
+	IssuesEvent       = "issues"        // the X-GitHub-Event of issue changes
+	IssueCommentEvent = "issue_comment" // the X-GitHub-Event of issue comments
+
+	RateLimitBackoff = time.Minute // the pause after a rate limited response which doesn't say when to retry
+
+	EnterpriseAPIPath = "/api/v3" // the REST API root of a Github Enterprise host, under https://<host>
+
+	DefaultBaseURL = "https://api.github.com" // Github.com REST API root, Github Enterprise uses https://<host>/api/v3
+
+	GraphQLPath           = "/graphql"     // the GraphQL endpoint under Github.com's REST API root
+	GraphQLEnterprisePath = "/api/graphql" // the GraphQL endpoint of a Github Enterprise host, under https://<host>
+
+	GitlabAPIPath     = "/api/v4"                   // the REST API root of a GitLab host, under https://<host>
+	DefaultGitlabHost = "gitlab.com"                // the GitLab host reached without a hosts configuration
+	DefaultGitlabURL  = "https://gitlab.com/api/v4" // GitLab.com REST API root
+
+	GiteaAPIPath = "/api/v1" // the REST API root of a Gitea or Forgejo host, under https://<host>
+
+	JiraAPIPath            = "/rest/api/2" // the REST API root of a Jira host, under https://<host>
+	JiraIssueType          = "Task"        // the type of the issues created on Jira, unless the host configures another
+	JiraOpenTransition     = "To Do"       // the transition (or its target status) reopening a Jira issue, unless the host configures another
+	JiraCloseTransition    = "Done"        // the transition (or its target status) closing a Jira issue, unless the host configures another
+	JiraDoneStatusCategory = "done"        // the status category of a closed Jira issue
+
+	JiraMarkerProperty = "githubissues-operator" // the entity property holding the marker of a Jira issue or comment, which Jira would show in the text
+
+	APP_ERROR        = "Github App authentication error"
+	WEBHOOK_ERROR    = "Webhook delivery error"
+	RATE_LIMIT_ERROR = "Github rate limit error"
+	HOST_ERROR       = "Github host configuration error"
+
+	PATCH   = "PATCH call"
+	POST    = "POST call"
+	GET     = "GET call"
+	COMMENT = "Comment call"
+	LOCK    = "Lock call"
+	SEARCH  = "Search call"
+	LIST    = "List call"
+
+	EDIT_COMMENT   = "Edit comment call"
+	DELETE_COMMENT = "Delete comment call"
+	LIST_COMMENTS  = "List comments call"
+)
+
+// global Github App credentials, used instead of token when appID is set
+var (
+	appID         string
+	appPrivateKey []byte
 )
 
 var token string // Good link for using secrets -> https://kubernetes.io/docs/concepts/configuration/secret/#using-secrets-as-environment-variables
 
 // A GithubRecieve struct to map the entire Response
 type GithubRecieve struct {
-	Repo        string `json:"url"` // or `json:"html_url"`
-	Title       string `json:"title"`
-	Description string `json:"body"` // It is called 'body' in the json file
-	State       string `json:"state,omitempty"`
-	Number      int    `json:"number,omitempty"`
+	Repo        string           `json:"url"` // or `json:"html_url"`
+	Title       string           `json:"title"`
+	Description string           `json:"body"` // It is called 'body' in the json file
+	State       string           `json:"state,omitempty"`
+	StateReason string           `json:"state_reason,omitempty"`
+	Number      int              `json:"number,omitempty"`
+	Labels      []GithubLabel    `json:"labels,omitempty"`
+	Assignees   []GithubUser     `json:"assignees,omitempty"`
+	Milestone   *GithubMilestone `json:"milestone,omitempty"`
+	// Key identifies the issue on a tracker which has keys, e.g. Jira's OPS-123
+	Key string `json:"-"`
+	// PullRequest is set on the pull requests, which Github lists among the issues
+	PullRequest *GithubPullRequest `json:"pull_request,omitempty"`
+}
+
+// GithubPullRequest links a listed issue to its pull request
+type GithubPullRequest struct {
+	URL string `json:"url"`
+}
+
+// GithubLabel is a label of an issue
+type GithubLabel struct {
+	Name string `json:"name"`
+}
+
+// GithubUser is an assignee of an issue
+type GithubUser struct {
+	Login string `json:"login"`
+}
+
+// GithubMilestone is the milestone of an issue
+type GithubMilestone struct {
+	Number int    `json:"number"`
+	Title  string `json:"title,omitempty"`
+}
+
+// LabelNames returns the names of the issue's labels
+func (issue GithubRecieve) LabelNames() []string {
+	names := make([]string, 0, len(issue.Labels))
+	for _, label := range issue.Labels {
+		names = append(names, label.Name)
+	}
+	return names
+}
+
+// AssigneeLogins returns the logins of the issue's assignees
+func (issue GithubRecieve) AssigneeLogins() []string {
+	logins := make([]string, 0, len(issue.Assignees))
+	for _, assignee := range issue.Assignees {
+		logins = append(logins, assignee.Login)
+	}
+	return logins
+}
+
+// MilestoneNumber returns the number of the issue's milestone, zero when it has none
+func (issue GithubRecieve) MilestoneNumber() int {
+	if issue.Milestone == nil {
+		return 0
+	}
+	return issue.Milestone.Number
 }
 
 // GithubSend - specify data fields for new github issue submission
 type GithubSend struct {
-	Title       string `json:"title,omitempty"`
-	Body        string `json:"body,omitempty"`
-	State       string `json:"state,omitempty"`
-	ClosingTime string `json:"closed_at,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Body        string   `json:"body,omitempty"`
+	State       string   `json:"state,omitempty"`
+	StateReason string   `json:"state_reason,omitempty"`
+	ClosingTime string   `json:"closed_at,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	Assignees   []string `json:"assignees,omitempty"`
+	Milestone   *int     `json:"milestone,omitempty"`
+}
+
+// GithubComment is a comment of an issue
+type GithubComment struct {
+	ID   int64  `json:"id,omitempty"`
+	Body string `json:"body"`
+}
+
+// GithubLock - the reason for locking an issue's conversation
+type GithubLock struct {
+	Reason string `json:"lock_reason,omitempty"`
+}
+
+// GithubSearch - the issues found by a search
+type GithubSearch struct {
+	TotalCount int             `json:"total_count"`
+	Items      []GithubRecieve `json:"items"`
 }
@@ -0,0 +1,72 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// WebhookEvent is the part of an issues or issue_comment webhook delivery the operator uses -
+// https://docs.github.com/en/developers/webhooks-and-events/webhooks/webhook-events-and-payloads#issues
+type WebhookEvent struct {
+	Action     string        `json:"action"`
+	Issue      GithubRecieve `json:"issue"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+}
+
+// ValidateSignature checks the X-Hub-Signature-256 header of a delivery - the hex HMAC-SHA256 of payload keyed by secret
+func ValidateSignature(payload []byte, signature string, secret []byte) error {
+	if len(secret) == 0 {
+		return fmt.Errorf("%v: no webhook secret is configured", WEBHOOK_ERROR)
+	}
+	if !strings.HasPrefix(signature, SignaturePrefix) {
+		return fmt.Errorf("%v: missing %s signature", WEBHOOK_ERROR, SignaturePrefix)
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, SignaturePrefix))
+	if err != nil {
+		return fmt.Errorf("%v: malformed signature :%w", WEBHOOK_ERROR, err)
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return fmt.Errorf("%v: signature mismatch", WEBHOOK_ERROR)
+	}
+	return nil
+}
+
+// ParseWebhook decodes a delivery of eventType, it returns false for event types which don't concern issues
+func ParseWebhook(eventType string, payload []byte) (WebhookEvent, bool, error) {
+	var event WebhookEvent
+	if eventType != IssuesEvent && eventType != IssueCommentEvent {
+		return event, false, nil
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return event, false, fmt.Errorf("%v: malformed payload :%w", WEBHOOK_ERROR, err)
+	}
+	if event.Issue.Number == 0 || event.Repository.HTMLURL == "" {
+		return event, false, fmt.Errorf("%v: the delivery has no issue number or repository", WEBHOOK_ERROR)
+	}
+	return event, true, nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2021 Or Raz.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Github webhooks", func() {
+	secret := []byte("It's a Secret to Everybody")
+	payload := []byte(`{"action":"edited","issue":{"number":7,"title":"t"},"repository":{"full_name":"razo7/githubissues-operator","html_url":"https://github.com/razo7/githubissues-operator"}}`)
+
+	sign := func(payload []byte, secret []byte) string {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(payload)
+		return SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	It("should accept a delivery signed with the secret", func() {
+		Expect(ValidateSignature(payload, sign(payload, secret), secret)).To(Succeed())
+	})
+
+	It("should reject a wrong, malformed or missing signature", func() {
+		Expect(ValidateSignature(payload, sign(payload, []byte("another secret")), secret)).NotTo(Succeed())
+		Expect(ValidateSignature(append(payload, ' '), sign(payload, secret), secret)).NotTo(Succeed())
+		Expect(ValidateSignature(payload, SignaturePrefix+"zz", secret)).NotTo(Succeed())
+		Expect(ValidateSignature(payload, "", secret)).NotTo(Succeed())
+	})
+
+	It("should reject every delivery when no secret is configured", func() {
+		Expect(ValidateSignature(payload, sign(payload, nil), nil)).NotTo(Succeed())
+	})
+
+	It("should parse the repo and number of issues and issue_comment deliveries", func() {
+		for _, eventType := range []string{IssuesEvent, IssueCommentEvent} {
+			event, relevant, err := ParseWebhook(eventType, payload)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(relevant).To(BeTrue())
+			Expect(event.Issue.Number).To(Equal(7))
+			Expect(event.Repository.HTMLURL).To(Equal("https://github.com/razo7/githubissues-operator"))
+		}
+	})
+
+	It("should ignore other events and fail on a malformed delivery", func() {
+		_, relevant, err := ParseWebhook("ping", []byte(`{"zen":"Keep it logically awesome."}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(relevant).To(BeFalse())
+		_, _, err = ParseWebhook(IssuesEvent, []byte(`{"action":`))
+		Expect(err).To(HaveOccurred())
+		_, _, err = ParseWebhook(IssuesEvent, []byte(`{"action":"opened"}`))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -18,7 +18,9 @@ package main
 
 import (
 	"flag"
+	"net/http"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -28,11 +30,13 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	trainingv1alpha1 "github.com/razo7/githubissues-operator/api/v1alpha1"
 	"github.com/razo7/githubissues-operator/controllers"
+	githubApi "github.com/razo7/githubissues-operator/github"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -52,8 +56,26 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var githubAPIURL string
+	var hostsConfigPath string
+	var webhookAddr string
+	var resyncPeriod time.Duration
+	var graphQLResyncPeriod time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.StringVar(&githubAPIURL, "github-api-url", githubApi.DefaultBaseURL,
+		"The Github.com REST API root, e.g. for going through a proxy.")
+	flag.StringVar(&hostsConfigPath, "github-hosts-config", "",
+		"The file configuring the Github Enterprise hosts - their API URL, credentials Secret and CA bundle. "+
+			"Empty or a missing file means only github.com repos are served.")
+	flag.StringVar(&webhookAddr, "github-webhook-bind-address", "",
+		"The address the Github webhook receiver binds to, e.g. :9090. Empty disables it. "+
+			"The webhook secret is read from the GIT_WEBHOOK_SECRET environment variable.")
+	flag.DurationVar(&resyncPeriod, "resync-period", controllers.DefaultResyncPeriod,
+		"How often every GithubIssue is compared with its issue. Can be lengthened to hours once webhooks are enabled.")
+	flag.DurationVar(&graphQLResyncPeriod, "graphql-resync-period", 0,
+		"How often the managed Github issues of a repo are fetched together with one GraphQL query, which the resyncs of "+
+			"the repo's GithubIssues read instead of a REST call each, e.g. 1m. Zero disables it.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -65,27 +87,97 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 	// os.Setenv("KUBECONFIG", "/home/oraz/.kube/kube_config_dsal")
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), controllers.SecretCacheOptions(ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "f1797758.githubissues",
-	})
+	}))
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.GithubIssueReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Log:    ctrl.Log.WithName("controllers").WithName("GitHubIssue"),
-	}).SetupWithManager(mgr); err != nil {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	hostsConfig := githubApi.HostsConfig{}
+	if hostsConfigPath != "" {
+		if hostsConfig, err = githubApi.LoadHostsConfig(hostsConfigPath); err != nil && !os.IsNotExist(err) {
+			setupLog.Error(err, "unable to load the Github hosts configuration")
+			os.Exit(1)
+		}
+	}
+	hosts, err := githubApi.NewHosts(hostsConfig, httpClient.Timeout)
+	if err != nil {
+		setupLog.Error(err, "unable to configure the Github hosts")
+		os.Exit(1)
+	}
+	githubClient := githubApi.NewClient(githubAPIURL, httpClient)
+	if graphQLResyncPeriod > 0 {
+		githubClient.Batches = githubApi.NewIssueBatches(graphQLResyncPeriod)
+		hosts.EnableBatches(graphQLResyncPeriod)
+	}
+	var webhookEvents chan event.GenericEvent
+	var webhookReceiver *controllers.WebhookReceiver
+	if webhookAddr != "" {
+		secret := os.Getenv("GIT_WEBHOOK_SECRET")
+		if secret == "" {
+			setupLog.Error(nil, "GIT_WEBHOOK_SECRET must be set when --github-webhook-bind-address is")
+			os.Exit(1)
+		}
+		webhookEvents = make(chan event.GenericEvent, 100)
+		webhookReceiver = &controllers.WebhookReceiver{
+			Client:      mgr.GetClient(),
+			Log:         ctrl.Log.WithName("webhook").WithName("GitHubIssue"),
+			Secret:      []byte(secret),
+			Events:      webhookEvents,
+			BindAddress: webhookAddr,
+		}
+	}
+	issueReconciler := &controllers.GithubIssueReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Log:           ctrl.Log.WithName("controllers").WithName("GitHubIssue"),
+		Recorder:      mgr.GetEventRecorderFor("githubissue-controller"),
+		GithubClient:  githubClient,
+		GitlabClient:  githubApi.NewGitlabClient(githubApi.DefaultGitlabURL, httpClient),
+		AppTokens:     githubApi.NewAppTokenSource(githubAPIURL, httpClient),
+		Hosts:         hosts,
+		WebhookEvents: webhookEvents,
+		ResyncPeriod:  resyncPeriod,
+	}
+	if err = issueReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "GithubIssue")
 		os.Exit(1)
 	}
+	if webhookReceiver != nil {
+		webhookReceiver.Forget = issueReconciler.ForgetIssue // a delivered change isn't hidden by a GraphQL batch fetched before it
+		if err := mgr.Add(webhookReceiver); err != nil {
+			setupLog.Error(err, "unable to add the Github webhook receiver")
+			os.Exit(1)
+		}
+	}
+	if err = (&controllers.GithubIssueImportReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Log:      ctrl.Log.WithName("controllers").WithName("GithubIssueImport"),
+		Recorder: mgr.GetEventRecorderFor("githubissueimport-controller"),
+		Issues:   issueReconciler,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GithubIssueImport")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&trainingv1alpha1.GithubIssue{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "GithubIssue")
+			os.Exit(1)
+		}
+		if err = (&trainingv1alpha1.GithubIssueImport{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "GithubIssueImport")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {